@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +15,47 @@ type Config struct {
 	DBName    string
 	Port      string
 	JWTSecret string
+
+	// Websocket hub settings
+	WSSendQueueSize   int
+	WSBroadcastBuffer int
+	WSPingInterval    time.Duration
+	WSPongTimeout     time.Duration
+	WSIdleTimeout     time.Duration
+	EventLogSize      int
+
+	// How long deleted tasks are remembered for delta sync
+	TombstoneRetention time.Duration
+
+	// How far ahead of its due date a task counts as due soon
+	DueSoonWindow time.Duration
+
+	// Whether searches are ranked by Mongo's text index; false ranks them in process
+	SearchTextIndex bool
+
+	// Reminder scheduler settings; an interval of zero turns reminders off
+	ReminderInterval   time.Duration
+	ReminderCatchUp    time.Duration
+	ReminderWebhookURL string
+
+	// How long deleted tasks stay in the trash, and how often expired ones are purged; an
+	// interval of zero turns purging off
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	// Attachment storage: "local" keeps files in AttachmentDir, "s3" in an S3-compatible bucket
+	AttachmentStorage string
+	AttachmentDir     string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKey       string
+	S3SecretKey       string
+
+	// Attachment limits and how long signed download URLs stay valid
+	AttachmentMaxSize int
+	AttachmentTypes   []string
+	AttachmentURLTTL  time.Duration
 }
 
 func Load() *Config {
@@ -25,6 +69,36 @@ func Load() *Config {
 		DBName:    getEnv("DB_NAME", "kanban_board"),
 		Port:      getEnv("PORT", "3000"),
 		JWTSecret: getEnv("JWT_SECRET", ""),
+
+		WSSendQueueSize:   getEnvInt("WS_SEND_QUEUE_SIZE", 256),
+		WSBroadcastBuffer: getEnvInt("WS_BROADCAST_BUFFER", 1024),
+		WSPingInterval:    getEnvDuration("WS_PING_INTERVAL", 30*time.Second),
+		WSPongTimeout:     getEnvDuration("WS_PONG_TIMEOUT", 60*time.Second),
+		WSIdleTimeout:     getEnvDuration("WS_IDLE_TIMEOUT", 0),
+		EventLogSize:      getEnvInt("EVENT_LOG_SIZE", 1000),
+
+		TombstoneRetention: getEnvDuration("TOMBSTONE_RETENTION", 30*24*time.Hour),
+		DueSoonWindow:      getEnvDuration("DUE_SOON_WINDOW", 48*time.Hour),
+		SearchTextIndex:    getEnvBool("SEARCH_TEXT_INDEX", true),
+
+		ReminderInterval:   getEnvInterval("REMINDER_INTERVAL", time.Minute),
+		ReminderCatchUp:    getEnvDuration("REMINDER_CATCH_UP", time.Hour),
+		ReminderWebhookURL: getEnv("REMINDER_WEBHOOK_URL", ""),
+
+		TrashRetention:     getEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval: getEnvInterval("TRASH_PURGE_INTERVAL", time.Hour),
+
+		AttachmentStorage: getEnv("ATTACHMENT_STORAGE", "local"),
+		AttachmentDir:     getEnv("ATTACHMENT_DIR", "data/attachments"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3AccessKey:       getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:       getEnv("S3_SECRET_KEY", ""),
+
+		AttachmentMaxSize: getEnvInt("ATTACHMENT_MAX_SIZE", 10<<20),
+		AttachmentTypes:   getEnvList("ATTACHMENT_TYPES", "image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain,text/csv,application/zip"),
+		AttachmentURLTTL:  getEnvDuration("ATTACHMENT_URL_TTL", 15*time.Minute),
 	}
 }
 
@@ -34,3 +108,68 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("Invalid value %q for %s, using default %d", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %t", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvList reads a comma-separated list, dropping empty entries
+func getEnvList(key, fallback string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvDuration reads a duration such as "30s". Zero is only accepted for settings
+// that default to zero, i.e. ones that can be disabled.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	return parseEnvDuration(key, fallback, fallback == 0)
+}
+
+// getEnvInterval reads how often a background job runs. Zero is accepted and turns the
+// job off.
+func getEnvInterval(key string, fallback time.Duration) time.Duration {
+	return parseEnvDuration(key, fallback, true)
+}
+
+func parseEnvDuration(key string, fallback time.Duration, allowZero bool) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 || (parsed == 0 && !allowZero) {
+		log.Printf("Invalid value %q for %s, using default %s", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		fallback time.Duration
+		want     time.Duration
+	}{
+		{"unset", "", time.Minute, time.Minute},
+		{"set", "30s", time.Minute, 30 * time.Second},
+		{"invalid", "soon", time.Minute, time.Minute},
+		{"negative", "-1s", time.Minute, time.Minute},
+		{"zero with a default", "0", time.Minute, time.Minute},
+		{"zero without a default", "0", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_DURATION", tt.value)
+			if got := getEnvDuration("TEST_DURATION", tt.fallback); got != tt.want {
+				t.Errorf("getEnvDuration(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvInterval(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"unset", "", time.Minute},
+		{"set", "5m", 5 * time.Minute},
+		{"zero", "0", 0},
+		{"zero with a unit", "0s", 0},
+		{"invalid", "often", time.Minute},
+		{"negative", "-1m", time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_INTERVAL", tt.value)
+			if got := getEnvInterval("TEST_INTERVAL", time.Minute); got != tt.want {
+				t.Errorf("getEnvInterval(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDisablesJobsWithZeroInterval(t *testing.T) {
+	t.Setenv("REMINDER_INTERVAL", "0")
+	t.Setenv("TRASH_PURGE_INTERVAL", "0")
+
+	cfg := Load()
+	if cfg.ReminderInterval != 0 {
+		t.Errorf("ReminderInterval = %s, want 0", cfg.ReminderInterval)
+	}
+	if cfg.TrashPurgeInterval != 0 {
+		t.Errorf("TrashPurgeInterval = %s, want 0", cfg.TrashPurgeInterval)
+	}
+}
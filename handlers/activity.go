@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// historyFields are the task fields whose changes are recorded. Bookkeeping such as the
+// rank and the version is left out.
+var historyFields = []string{
+	fieldName,
+	fieldDescription,
+	fieldStatus,
+	fieldStartDate,
+	fieldDueDate,
+	fieldTimeZone,
+	fieldLabels,
+	fieldAssignees,
+	fieldChecklist,
+}
+
+// taskSnapshot captures the task's recorded fields before a change, for recordActivity
+func taskSnapshot(task *models.Task) map[string]json.RawMessage {
+	doc, err := taskDocument(task)
+	if err != nil {
+		log.Printf("Error capturing task %s for its history: %v", task.ID.Hex(), err)
+	}
+	return doc
+}
+
+// emptyValue reports whether a JSON value stands for an unset field
+func emptyValue(value json.RawMessage) bool {
+	switch string(value) {
+	case "", "null", "[]", `""`:
+		return true
+	}
+	return false
+}
+
+// taskChanges lists the recorded fields that differ between two snapshots. A nil before
+// snapshot lists every field that is set.
+func taskChanges(before, after map[string]json.RawMessage) []models.FieldChange {
+	changes := []models.FieldChange{}
+	for _, field := range historyFields {
+		from, to := before[field], after[field]
+		if (emptyValue(from) && emptyValue(to)) || bytes.Equal(from, to) {
+			continue
+		}
+		if emptyValue(from) {
+			from = json.RawMessage("null")
+		}
+		if emptyValue(to) {
+			to = json.RawMessage("null")
+		}
+		changes = append(changes, models.FieldChange{Field: field, From: from, To: to})
+	}
+	return changes
+}
+
+// recordActivity adds a change of the task, as it now stands, to its history. before is the
+// snapshot taken ahead of the change; it is nil for changes without a field-level diff,
+// such as deleting a task, and for new tasks, whose every set field counts as changed.
+// Updates that changed no recorded field are left out. Failures are logged rather than
+// failing the change itself.
+func recordActivity(action string, task *models.Task, actorID primitive.ObjectID, before map[string]json.RawMessage) {
+	changes := []models.FieldChange{}
+	var previousRank string
+	if before != nil || action == models.ActivityCreate {
+		after := taskSnapshot(task)
+		changes = taskChanges(before, after)
+		if before != nil && !bytes.Equal(before[fieldRank], after[fieldRank]) {
+			_ = json.Unmarshal(before[fieldRank], &previousRank)
+		}
+	}
+	if action == models.ActivityUpdate && len(changes) == 0 && previousRank == "" {
+		return
+	}
+
+	activity := models.Activity{
+		BoardID:   task.BoardID,
+		TaskID:    task.ID,
+		UserID:    actorID,
+		Action:    action,
+		TaskName:  task.Name,
+		Version:   task.Version,
+		Changes:   changes,
+		CreatedAt: time.Now().UTC(),
+
+		PreviousRank: previousRank,
+	}
+	if _, err := ActivityService.InsertOne(activity); err != nil {
+		log.Printf("Error recording activity on task %s: %v", task.ID.Hex(), err)
+	}
+}
+
+// GetTaskHistory lists the changes to a task, newest first
+func GetTaskHistory(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	if _, _, err := authorizeTask(id, userObjectID, models.RoleViewer); err != nil {
+		return sendError(c, err)
+	}
+
+	filter := bson.M{fieldTaskID: id}
+	opts, err := feedPage(c, filter)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	history := []models.Activity{}
+	if err := ActivityService.FindWithOptions(filter, opts, &history); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(history)
+}
+
+// GetBoardActivity lists the changes to all tasks of a board, newest first, including
+// tasks that have since been deleted
+func GetBoardActivity(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	if _, err := authorizeBoard(id, userObjectID, models.RoleViewer); err != nil {
+		return sendError(c, err)
+	}
+
+	filter := bson.M{fieldBoardID: id}
+	if value := c.Query(queryActor); value != "" {
+		actorID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+		}
+		filter[fieldUserID] = actorID
+	}
+	opts, err := feedPage(c, filter)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	activity := []models.Activity{}
+	if err := ActivityService.FindWithOptions(filter, opts, &activity); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(activity)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// archivedQueryFilter hides archived tasks unless the archived query parameter asks for
+// them: true lists only archived tasks and all lists both
+func archivedQueryFilter(c *fiber.Ctx, _ []models.Board) (bson.M, error) {
+	switch c.Query(queryArchived) {
+	case "", "false":
+		return bson.M{fieldArchived: bson.M{"$ne": true}}, nil
+	case "true":
+		return bson.M{fieldArchived: true}, nil
+	case "all":
+		return nil, nil
+	}
+	return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidArchivedFilter)
+}
+
+// setArchived archives or unarchives the task and tells the board's clients. action is
+// recorded in the task's history. Tasks already in that state are left as they are.
+func setArchived(task *models.Task, board *models.Board, userID primitive.ObjectID, archived bool, action string) error {
+	if task.Archived == archived {
+		return nil
+	}
+
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now().UTC()
+		archivedAt = &now
+	}
+	if err := updateTaskVersion(task, bson.M{fieldArchived: archived, fieldArchivedAt: archivedAt}); err != nil {
+		return err
+	}
+
+	recordActivity(action, task, userID, nil)
+
+	BroadcastTaskChange(messageTypePatch, board, task, userID, taskFieldValues(task, []string{fieldArchived, fieldArchivedAt, fieldUpdatedAt}))
+	return nil
+}
+
+// changeArchived handles the archive and unarchive endpoints
+func changeArchived(c *fiber.Ctx, archived bool) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := checkIfMatch(c, task); err != nil {
+		return sendError(c, err)
+	}
+
+	action := models.ActivityUnarchive
+	if archived {
+		action = models.ActivityArchive
+	}
+	if err := setArchived(task, board, userObjectID, archived, action); err != nil {
+		return sendError(c, err)
+	}
+
+	setDueFlags(board, task)
+	setTaskETag(c, task)
+	return c.JSON(task)
+}
+
+// ArchiveTask hides a task from task lists without deleting it
+func ArchiveTask(c *fiber.Ctx) error {
+	return changeArchived(c, true)
+}
+
+// UnarchiveTask puts an archived task back on its board, where it was
+func UnarchiveTask(c *fiber.Ctx) error {
+	return changeArchived(c, false)
+}
+
+// ArchiveDoneTasks archives the tasks in the board's last column that have not changed
+// for the given number of days. Tasks changed while they are being archived are skipped.
+func ArchiveDoneTasks(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ArchiveTasksRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if req.OlderThanDays == nil || *req.OlderThanDays < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidArchiveAge})
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -*req.OlderThanDays)
+	tasks := []models.Task{}
+	filter := bson.M{
+		fieldBoardID:   id,
+		fieldStatus:    doneStatus(board),
+		fieldArchived:  bson.M{"$ne": true},
+		fieldUpdatedAt: bson.M{"$lte": cutoff},
+	}
+	if err := TaskService.Find(filter, &tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	response := ArchiveTasksResponse{TaskIDs: []primitive.ObjectID{}}
+	for i := range tasks {
+		if err := setArchived(&tasks[i], board, userObjectID, true, models.ActivityArchive); err != nil {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusPreconditionFailed {
+				continue
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+		response.TaskIDs = append(response.TaskIDs, tasks[i].ID)
+	}
+	response.Archived = len(response.TaskIDs)
+
+	return c.JSON(response)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// resolveAssignees checks that every assignee is a member of the board and drops
+// duplicates, keeping the order the client gave
+func resolveAssignees(board *models.Board, userIDs []primitive.ObjectID) ([]primitive.ObjectID, bool) {
+	resolved := make([]primitive.ObjectID, 0, len(userIDs))
+	seen := make(map[primitive.ObjectID]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if board.RoleOf(userID) == "" {
+			return nil, false
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			resolved = append(resolved, userID)
+		}
+	}
+	return resolved, true
+}
+
+// assignedToFilter matches tasks assigned to the user
+func assignedToFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{fieldAssignees: userID}
+}
+
+// unassignedFilter matches tasks nobody is assigned to
+func unassignedFilter() bson.M {
+	return bson.M{fieldAssignees: bson.M{"$in": bson.A{nil, bson.A{}}}}
+}
+
+// assigneeQueryFilter keeps tasks assigned to any of the listed users, given as IDs or
+// "me", or unassigned tasks for "none"
+func assigneeQueryFilter(c *fiber.Ctx, _ []models.Board) (bson.M, error) {
+	assignees := splitQueryList(c.Query(queryAssignee))
+	if len(assignees) == 0 {
+		return nil, nil
+	}
+
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []bson.M
+	for _, assignee := range assignees {
+		switch {
+		case strings.EqualFold(assignee, "me"):
+			matches = append(matches, assignedToFilter(userObjectID))
+		case strings.EqualFold(assignee, "none"):
+			matches = append(matches, unassignedFilter())
+		default:
+			userID, err := primitive.ObjectIDFromHex(assignee)
+			if err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidUserID+": "+assignee)
+			}
+			matches = append(matches, assignedToFilter(userID))
+		}
+	}
+	return bson.M{"$or": matches}, nil
+}
+
+// setAssignees stores the task's new assignees and tells the board's clients
+func setAssignees(c *fiber.Ctx, task *models.Task, board *models.Board, userID primitive.ObjectID, assignees []primitive.ObjectID) error {
+	before := taskSnapshot(task)
+	if err := updateTaskVersion(task, bson.M{fieldAssignees: assignees}); err != nil {
+		return sendError(c, err)
+	}
+	setDueFlags(board, task)
+
+	recordActivity(models.ActivityUpdate, task, userID, before)
+
+	BroadcastTaskChange(messageTypePatch, board, task, userID, taskFieldValues(task, []string{fieldAssignees, fieldUpdatedAt}))
+
+	setTaskETag(c, task)
+	return c.JSON(task)
+}
+
+func AssignTask(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := checkIfMatch(c, task); err != nil {
+		return sendError(c, err)
+	}
+
+	var req AssigneeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if req.UserID.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+	}
+
+	// Only members of the board can work on its tasks
+	if board.RoleOf(req.UserID) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errAssigneeNotMember})
+	}
+
+	// Assigning someone twice changes nothing
+	for _, assignee := range task.Assignees {
+		if assignee == req.UserID {
+			setDueFlags(board, task)
+			setTaskETag(c, task)
+			return c.JSON(task)
+		}
+	}
+
+	assignees := append(append([]primitive.ObjectID{}, task.Assignees...), req.UserID)
+	return setAssignees(c, task, board, userObjectID, assignees)
+}
+
+func UnassignTask(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	assigneeID, err := primitive.ObjectIDFromHex(c.Params(paramUserID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := checkIfMatch(c, task); err != nil {
+		return sendError(c, err)
+	}
+
+	assignees := make([]primitive.ObjectID, 0, len(task.Assignees))
+	for _, assignee := range task.Assignees {
+		if assignee != assigneeID {
+			assignees = append(assignees, assignee)
+		}
+	}
+	if len(assignees) == len(task.Assignees) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errAssigneeNotFound})
+	}
+
+	return setAssignees(c, task, board, userObjectID, assignees)
+}
+
+// GetAssignedTasks lists the tasks assigned to the caller across all of their boards.
+// It takes the same filters, sorting and paging as GET /tasks.
+func GetAssignedTasks(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	boards, err := visibleBoards(c, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	page, err := queryTasks(c, boards, assignedToFilter(userObjectID))
+	if err != nil {
+		return sendError(c, err)
+	}
+	setDueFlagsAll(page.Tasks, boards)
+	return sendTasks(c, page)
+}
+
+// unassignMember takes a user who left the board off all of its tasks
+func unassignMember(boardID, userID primitive.ObjectID) error {
+	filter := bson.M{fieldBoardID: boardID, fieldAssignees: userID}
+	update := bson.M{
+		"$pull": bson.M{fieldAssignees: userID},
+		"$set":  bson.M{fieldUpdatedAt: time.Now().UTC()},
+		"$inc":  bson.M{fieldVersion: 1},
+	}
+	return TaskService.UpdateManyRaw(filter, update)
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/storage"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxAttachmentNameLength caps the length of an attachment's file name, in characters
+	maxAttachmentNameLength = 255
+
+	// sniffLength is how much of an upload is looked at to detect its type
+	sniffLength = 512
+)
+
+// attachments holds the attachment settings; see ConfigureAttachments
+var attachments = AttachmentConfig{
+	MaxSize: 10 << 20,
+	URLTTL:  15 * time.Minute,
+}
+
+// ConfigureAttachments sets where attachments are stored and what may be uploaded
+func ConfigureAttachments(config AttachmentConfig) {
+	attachments = config
+}
+
+// attachmentTypeAllowed reports whether files of the type may be uploaded. Allowed types
+// are either exact, as in "application/pdf", or cover a family, as in "image/*".
+func attachmentTypeAllowed(contentType string) bool {
+	for _, allowed := range attachments.Types {
+		allowed = strings.ToLower(allowed)
+		if allowed == contentType || allowed == "*/*" ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentContentType detects the type of an upload from its first bytes and checks it
+// against the allowed types. A type the client declared for the file has to be allowed as
+// well. Where detection only tells that the file is text, the declared type names it, as
+// for CSV. When the upload is not allowed, the type returned is the one that was refused.
+func attachmentContentType(header *multipart.FileHeader, file io.ReadSeeker) (string, bool, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", false, err
+	}
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+
+	declared, _, _ := mime.ParseMediaType(header.Header.Get(fiber.HeaderContentType))
+	declared = strings.ToLower(declared)
+	if declared == fiber.MIMEOctetStream {
+		declared = ""
+	}
+
+	if declared != "" && detected == fiber.MIMETextPlain {
+		return declared, attachmentTypeAllowed(declared), nil
+	}
+	if !attachmentTypeAllowed(detected) {
+		return detected, false, nil
+	}
+	if declared != "" && !attachmentTypeAllowed(declared) {
+		return declared, false, nil
+	}
+	return detected, true, nil
+}
+
+// attachmentFileName keeps the last element of the uploaded file's name, without control
+// characters
+func attachmentFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxAttachmentNameLength {
+		name = string(runes[:maxAttachmentNameLength])
+	}
+	return name
+}
+
+// attachmentSignature signs a download of the attachment until the expiry time
+func attachmentSignature(id primitive.ObjectID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(JWTSecret))
+	mac.Write([]byte("attachment:" + id.Hex() + ":" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signAttachmentURL sets the attachment's download URL, which works without a bearer
+// token until it expires
+func signAttachmentURL(attachment *models.Attachment, now time.Time) {
+	expires := now.Add(attachments.URLTTL).Unix()
+	attachment.URL = "/attachments/" + attachment.ID.Hex() +
+		"?" + queryExpires + "=" + strconv.FormatInt(expires, 10) +
+		"&" + querySignature + "=" + attachmentSignature(attachment.ID, expires)
+}
+
+// findTaskAttachment loads an attachment of the task named by the attachmentId route parameter
+func findTaskAttachment(c *fiber.Ctx, task *models.Task) (*models.Attachment, error) {
+	attachmentID, err := primitive.ObjectIDFromHex(c.Params(paramAttachmentID))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+	}
+
+	var attachment models.Attachment
+	if err := AttachmentService.FindOne(bson.M{"_id": attachmentID, fieldTaskID: task.ID}, &attachment); err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, errAttachmentNotFound)
+	}
+	return &attachment, nil
+}
+
+// sendAttachment streams the attachment's file. It is always offered as a download, so
+// that browsers never render an uploaded file as part of the site.
+func sendAttachment(c *fiber.Ctx, attachment *models.Attachment) error {
+	body, err := attachments.Storage.Open(c.UserContext(), attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errAttachmentNotFound})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderContentDisposition, disposition)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendStream(body, int(attachment.Size))
+}
+
+// deleteAttachments deletes the matching attachments and their files. Files that cannot
+// be deleted are logged and left behind rather than failing the deletion.
+func deleteAttachments(filter bson.M) error {
+	var found []models.Attachment
+	if err := AttachmentService.Find(filter, &found); err != nil {
+		return err
+	}
+	if err := AttachmentService.DeleteMany(filter); err != nil {
+		return err
+	}
+
+	for _, attachment := range found {
+		if err := attachments.Storage.Delete(context.Background(), attachment.StorageKey); err != nil {
+			log.Printf("Error deleting attachment file %s: %v", attachment.StorageKey, err)
+		}
+	}
+	return nil
+}
+
+// broadcastAttachment tells the board's clients about an attachment added to or removed from the task
+func broadcastAttachment(action string, board *models.Board, task *models.Task, userID primitive.ObjectID, attachment *models.Attachment) {
+	BroadcastTaskChange(messageTypeAttachment, board, task, userID, AttachmentEvent{Action: action, Attachment: attachment})
+}
+
+func GetAttachments(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	if _, _, err := authorizeTask(id, userObjectID, models.RoleViewer); err != nil {
+		return sendError(c, err)
+	}
+
+	found := []models.Attachment{}
+	opts := options.Find().SetSort(bson.D{{Key: fieldCreatedAt, Value: 1}, {Key: "_id", Value: 1}})
+	if err := AttachmentService.FindWithOptions(bson.M{fieldTaskID: id}, opts, &found); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	now := time.Now()
+	for i := range found {
+		signAttachmentURL(&found[i], now)
+	}
+
+	return c.JSON(found)
+}
+
+// UploadAttachment stores the file sent as the file field of a multipart form
+func UploadAttachment(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	header, err := c.FormFile(formFieldFile)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errAttachmentFileRequired})
+	}
+	if header.Size == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errAttachmentEmpty})
+	}
+	if header.Size > attachments.MaxSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{jsonFieldError: errAttachmentTooLarge})
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	defer file.Close()
+
+	contentType, allowed, err := attachmentContentType(header, file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{jsonFieldError: errAttachmentTypeNotAllowed + ": " + contentType})
+	}
+
+	attachment := models.Attachment{
+		ID:          primitive.NewObjectID(),
+		TaskID:      id,
+		BoardID:     task.BoardID,
+		UserID:      userObjectID,
+		FileName:    attachmentFileName(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		CreatedAt:   time.Now().UTC(),
+	}
+	attachment.StorageKey = task.BoardID.Hex() + "/" + id.Hex() + "/" + attachment.ID.Hex()
+
+	if err := attachments.Storage.Put(c.UserContext(), attachment.StorageKey, file, attachment.Size, contentType); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if _, err := AttachmentService.InsertOne(attachment); err != nil {
+		// Do not keep a file nothing refers to
+		if err := attachments.Storage.Delete(c.UserContext(), attachment.StorageKey); err != nil {
+			log.Printf("Error deleting attachment file %s: %v", attachment.StorageKey, err)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	signAttachmentURL(&attachment, time.Now())
+	broadcastAttachment(attachmentActionCreate, board, task, userObjectID, &attachment)
+
+	return c.Status(fiber.StatusCreated).JSON(attachment)
+}
+
+// DownloadAttachment sends an attachment's file to a member of the board
+func DownloadAttachment(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, _, err := authorizeTask(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	attachment, err := findTaskAttachment(c, task)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	return sendAttachment(c, attachment)
+}
+
+// DownloadSignedAttachment sends an attachment's file to anyone holding an unexpired
+// signed URL for it, such as an image tag or a browser download that cannot send a
+// bearer token
+func DownloadSignedAttachment(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	expires, err := strconv.ParseInt(c.Query(queryExpires), 10, 64)
+	signature := attachmentSignature(id, expires)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(c.Query(querySignature))) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errInvalidAttachmentURL})
+	}
+	if time.Now().Unix() > expires {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errAttachmentURLExpired})
+	}
+
+	var attachment models.Attachment
+	if err := AttachmentService.FindByID(id, &attachment); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errAttachmentNotFound})
+	}
+
+	return sendAttachment(c, &attachment)
+}
+
+func DeleteAttachment(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	attachment, err := findTaskAttachment(c, task)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := deleteAttachments(bson.M{"_id": attachment.ID}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	broadcastAttachment(attachmentActionDelete, board, task, userObjectID, attachment)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+func TestAttachmentContentType(t *testing.T) {
+	saved := attachments
+	defer func() { attachments = saved }()
+	attachments.Types = []string{"image/*", "application/pdf", "text/plain", "text/csv"}
+
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	tests := []struct {
+		declared string
+		content  string
+		want     string
+		allowed  bool
+	}{
+		{"", png, "image/png", true},
+		{"image/png", png, "image/png", true},
+		{"application/octet-stream", png, "image/png", true},
+		// The content decides, whatever the client claims
+		{"application/pdf", png, "image/png", true},
+		{"image/png", "<html><script>alert(1)</script></html>", "text/html", false},
+		{"image/png", "MZ\x90\x00\x03\x00\x00\x00\x04\x00", "application/octet-stream", false},
+		{"", "%PDF-1.7\n", "application/pdf", true},
+		// A declared type must be allowed too
+		{"application/x-msdownload", png, "application/x-msdownload", false},
+		// Text is named by the declared type
+		{"", "a,b\n1,2\n", "text/plain", true},
+		{"text/csv", "a,b\n1,2\n", "text/csv", true},
+		{"Text/CSV; charset=utf-8", "a,b\n1,2\n", "text/csv", true},
+		{"application/json", `{"a": 1}`, "application/json", false},
+	}
+	for _, tt := range tests {
+		header := &multipart.FileHeader{Header: textproto.MIMEHeader{}}
+		if tt.declared != "" {
+			header.Header.Set("Content-Type", tt.declared)
+		}
+		file := bytes.NewReader([]byte(tt.content))
+		got, allowed, err := attachmentContentType(header, file)
+		if err != nil {
+			t.Fatalf("attachmentContentType(%q, %q) failed: %v", tt.declared, tt.content, err)
+		}
+		if got != tt.want || allowed != tt.allowed {
+			t.Errorf("attachmentContentType(%q, %q) = %q, %v, want %q, %v", tt.declared, tt.content, got, allowed, tt.want, tt.allowed)
+		}
+		if file.Len() != len(tt.content) {
+			t.Errorf("attachmentContentType(%q, %q) left the file at offset %d", tt.declared, tt.content, len(tt.content)-file.Len())
+		}
+	}
+}
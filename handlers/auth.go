@@ -6,9 +6,11 @@ import (
 
 	"github.com/AttFlederX/kanban_board_server/middleware"
 	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"google.golang.org/api/idtoken"
 )
 
@@ -86,6 +88,8 @@ func GoogleSignIn(c *fiber.Ctx, jwtSecret string) error {
 		Email:    user.Email,
 		GoogleID: user.GoogleID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// Unique token ID so that the token can be revoked on sign-out
+			ID:        primitive.NewObjectID().Hex(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -104,3 +108,28 @@ func GoogleSignIn(c *fiber.Ctx, jwtSecret string) error {
 		User:  user,
 	})
 }
+
+func Logout(c *fiber.Ctx) error {
+	tokenID, _ := c.Locals(contextKeyTokenID).(string)
+	if tokenID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			jsonFieldError: errTokenNotRevocable,
+		})
+	}
+
+	expiresAt, ok := c.Locals(contextKeyTokenExpiresAt).(time.Time)
+	if !ok {
+		expiresAt = time.Now().Add(24 * time.Hour)
+	}
+
+	if err := services.RevokeToken(tokenID, expiresAt); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			jsonFieldError: errFailedRevokeToken,
+		})
+	}
+
+	// Websocket connections opened with the token go down with it
+	CloseTokenConnections(tokenID)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetBoards(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	// Find every board the authenticated user is a member of
+	boards := []models.Board{}
+	if err := BoardService.Find(memberBoardsFilter(userObjectID), &boards); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	return c.JSON(boards)
+}
+
+func GetBoard(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	return c.JSON(board)
+}
+
+func CreateBoard(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var board models.Board
+	if err := c.BodyParser(&board); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if board.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errBoardNameRequired})
+	}
+
+	// Force board to belong to authenticated user, who starts as its only member
+	board.OwnerID = userObjectID
+	board.Members = []models.BoardMember{{UserID: userObjectID, Role: models.RoleOwner}}
+	board.Labels = []models.Label{}
+
+	// Start from the default columns unless the client supplied its own
+	if len(board.Columns) == 0 {
+		board.Columns = defaultColumns()
+	} else {
+		requested := board.Columns
+		board.Columns = make([]models.Column, 0, len(requested))
+		for order, column := range requested {
+			column.ID = primitive.NewObjectID()
+			column.Name = strings.TrimSpace(column.Name)
+			column.Order = order
+			if msg := validateColumn(&board, column); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+			}
+			board.Columns = append(board.Columns, column)
+		}
+	}
+
+	id, err := BoardService.InsertOne(board)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	board.ID = id
+	return c.Status(fiber.StatusCreated).JSON(board)
+}
+
+func UpdateBoard(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	existingBoard, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var board models.Board
+	if err := c.BodyParser(&board); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if board.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errBoardNameRequired})
+	}
+
+	update := bson.M{
+		fieldName:        board.Name,
+		fieldDescription: board.Description,
+	}
+	if err := BoardService.UpdateByID(id, update); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	existingBoard.Name = board.Name
+	existingBoard.Description = board.Description
+	return c.JSON(existingBoard)
+}
+
+func DeleteBoard(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleOwner)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	// Remove the board's tasks before the board itself so none are left orphaned
+	if err := TaskService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := EventService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := FilterService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := CommentService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := NotificationService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := deleteAttachments(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := ActivityService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := TrashService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := TombstoneService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := ReminderService.DeleteMany(bson.M{fieldBoardID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := BoardService.DeleteByID(id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	for _, member := range boardMembers(board) {
+		RevokeBoardAccess(id, member.UserID)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// updateBoardIf applies an update to the board as long as filter still matches it, and
+// reads the board back. It returns false when filter no longer matches, because another
+// edit got there first. Edits to parts of a board go through here, so that concurrent
+// edits to other parts are kept.
+func updateBoardIf(board *models.Board, filter bson.M, update bson.M, opts *options.FindOneAndUpdateOptions) (bool, error) {
+	filter["_id"] = board.ID
+	if opts == nil {
+		opts = options.FindOneAndUpdate()
+	}
+	opts.SetReturnDocument(options.After)
+
+	var updated models.Board
+	err := BoardService.FindOneAndUpdate(filter, update, opts, &updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	*board = updated
+	return true, nil
+}
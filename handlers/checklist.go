@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxChecklistItems caps the length of a task's checklist
+const maxChecklistItems = 100
+
+// checklistProgress counts the finished items of the checklist
+func checklistProgress(items []models.ChecklistItem) models.ChecklistProgress {
+	progress := models.ChecklistProgress{Total: len(items)}
+	for _, item := range items {
+		if item.Done {
+			progress.Done++
+		}
+	}
+	return progress
+}
+
+// findChecklistItem returns the index of the item in the task's checklist, or -1
+func findChecklistItem(task *models.Task, itemID primitive.ObjectID) int {
+	for i, item := range task.Checklist {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeChecklist prepares the checklist of a new task: every item gets an ID and its
+// position as order, and the progress is counted
+func normalizeChecklist(task *models.Task) string {
+	if len(task.Checklist) > maxChecklistItems {
+		return errChecklistFull
+	}
+	for i := range task.Checklist {
+		task.Checklist[i].ID = primitive.NewObjectID()
+		task.Checklist[i].Text = strings.TrimSpace(task.Checklist[i].Text)
+		task.Checklist[i].Order = i
+		if task.Checklist[i].Text == "" {
+			return errChecklistTextRequired
+		}
+	}
+	task.Progress = checklistProgress(task.Checklist)
+	return ""
+}
+
+// checklistTask loads the task of a checklist request for editing and checks If-Match
+func checklistTask(c *fiber.Ctx) (*models.Task, *models.Board, primitive.ObjectID, error) {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return nil, nil, userObjectID, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return nil, nil, userObjectID, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return nil, nil, userObjectID, err
+	}
+
+	if err := checkIfMatch(c, task); err != nil {
+		return nil, nil, userObjectID, err
+	}
+	return task, board, userObjectID, nil
+}
+
+// checklistItemIndex reads the item ID from the route and finds the item in the task's checklist
+func checklistItemIndex(c *fiber.Ctx, task *models.Task) (int, error) {
+	itemID, err := primitive.ObjectIDFromHex(c.Params(paramItemID))
+	if err != nil {
+		return -1, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+	}
+	index := findChecklistItem(task, itemID)
+	if index < 0 {
+		return -1, fiber.NewError(fiber.StatusNotFound, errChecklistItemNotFound)
+	}
+	return index, nil
+}
+
+// saveChecklist stores the task's new checklist with its progress, tells the board's
+// clients what changed and answers with the updated task
+func saveChecklist(c *fiber.Ctx, task *models.Task, board *models.Board, userID primitive.ObjectID, items []models.ChecklistItem, action, itemID string, status int) error {
+	for i := range items {
+		items[i].Order = i
+	}
+	update := bson.M{fieldChecklist: items, fieldChecklistProgress: checklistProgress(items)}
+	before := taskSnapshot(task)
+	if err := updateTaskVersion(task, update); err != nil {
+		return sendError(c, err)
+	}
+	setDueFlags(board, task)
+
+	recordActivity(models.ActivityUpdate, task, userID, before)
+
+	BroadcastTaskChange(messageTypeChecklist, board, task, userID, ChecklistEvent{
+		Action:    action,
+		ItemID:    itemID,
+		Checklist: task.Checklist,
+		Progress:  task.Progress,
+		UpdatedAt: task.UpdatedAt,
+	})
+
+	setTaskETag(c, task)
+	return c.Status(status).JSON(task)
+}
+
+func AddChecklistItem(c *fiber.Ctx) error {
+	task, board, userObjectID, err := checklistTask(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ChecklistItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errChecklistTextRequired})
+	}
+	if len(task.Checklist) >= maxChecklistItems {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errChecklistFull})
+	}
+
+	// New items go to the end of the list
+	item := models.ChecklistItem{ID: primitive.NewObjectID(), Text: text}
+	items := append(append([]models.ChecklistItem{}, task.Checklist...), item)
+	return saveChecklist(c, task, board, userObjectID, items, checklistActionAdd, item.ID.Hex(), fiber.StatusCreated)
+}
+
+func UpdateChecklistItem(c *fiber.Ctx) error {
+	task, board, userObjectID, err := checklistTask(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	index, err := checklistItemIndex(c, task)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ChecklistItemUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// Only the supplied fields change, so ticking an item off sends just done
+	items := append([]models.ChecklistItem{}, task.Checklist...)
+	if req.Text != nil {
+		items[index].Text = strings.TrimSpace(*req.Text)
+		if items[index].Text == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errChecklistTextRequired})
+		}
+	}
+	if req.Done != nil {
+		items[index].Done = *req.Done
+	}
+
+	return saveChecklist(c, task, board, userObjectID, items, checklistActionUpdate, items[index].ID.Hex(), fiber.StatusOK)
+}
+
+func ReorderChecklist(c *fiber.Ctx) error {
+	task, board, userObjectID, err := checklistTask(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ReorderChecklistRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// The new order must name every item exactly once
+	if len(req.ItemIDs) != len(task.Checklist) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidChecklistOrder})
+	}
+
+	byID := make(map[primitive.ObjectID]models.ChecklistItem, len(task.Checklist))
+	for _, item := range task.Checklist {
+		byID[item.ID] = item
+	}
+
+	items := make([]models.ChecklistItem, 0, len(req.ItemIDs))
+	for _, itemID := range req.ItemIDs {
+		item, ok := byID[itemID]
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidChecklistOrder})
+		}
+		delete(byID, itemID)
+		items = append(items, item)
+	}
+
+	return saveChecklist(c, task, board, userObjectID, items, checklistActionReorder, "", fiber.StatusOK)
+}
+
+func DeleteChecklistItem(c *fiber.Ctx) error {
+	task, board, userObjectID, err := checklistTask(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	index, err := checklistItemIndex(c, task)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	itemID := task.Checklist[index].ID.Hex()
+	items := append(append([]models.ChecklistItem{}, task.Checklist[:index]...), task.Checklist[index+1:]...)
+	return saveChecklist(c, task, board, userObjectID, items, checklistActionRemove, itemID, fiber.StatusOK)
+}
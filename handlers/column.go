@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var columnColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// defaultColumns returns the columns every new board starts with
+func defaultColumns() []models.Column {
+	return []models.Column{
+		{ID: primitive.NewObjectID(), Name: "To Do", Order: 0, Color: "#9E9E9E"},
+		{ID: primitive.NewObjectID(), Name: "In Progress", Order: 1, Color: "#2196F3"},
+		{ID: primitive.NewObjectID(), Name: "Done", Order: 2, Color: "#4CAF50"},
+	}
+}
+
+// sortedColumns returns a copy of the board's columns in display order
+func sortedColumns(board *models.Board) []models.Column {
+	columns := append([]models.Column(nil), board.Columns...)
+	sort.SliceStable(columns, func(i, j int) bool {
+		return columns[i].Order < columns[j].Order
+	})
+	return columns
+}
+
+// broadcastColumns tells the board's clients about a change to its columns
+func broadcastColumns(action string, board *models.Board, userID primitive.ObjectID, columnID primitive.ObjectID, renamedFrom string, columns []models.Column) {
+	event := ColumnEvent{Action: action, RenamedFrom: renamedFrom, Columns: columns}
+	if !columnID.IsZero() {
+		event.ColumnID = columnID.Hex()
+	}
+	BroadcastBoardChange(messageTypeColumns, board, userID, event)
+}
+
+// normalizeColumnName reduces a column name to lowercase letters and digits so that
+// "To Do", "todo" and "TO_DO" all refer to the same column
+func normalizeColumnName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// resolveStatus maps a client-supplied status to the canonical column name of the board.
+// An empty status resolves to the first column.
+func resolveStatus(board *models.Board, status string) (string, bool) {
+	columns := sortedColumns(board)
+	if len(columns) == 0 {
+		return "", false
+	}
+
+	if strings.TrimSpace(status) == "" {
+		return columns[0].Name, true
+	}
+
+	normalized := normalizeColumnName(status)
+	for _, column := range columns {
+		if normalizeColumnName(column.Name) == normalized {
+			return column.Name, true
+		}
+	}
+	return "", false
+}
+
+// validateColumn checks a column definition against the other columns of the board
+func validateColumn(board *models.Board, column models.Column) string {
+	if normalizeColumnName(column.Name) == "" {
+		return errColumnNameRequired
+	}
+
+	if column.Color != "" && !columnColorPattern.MatchString(column.Color) {
+		return errInvalidColumnColor
+	}
+
+	normalized := normalizeColumnName(column.Name)
+	for _, existing := range board.Columns {
+		if existing.ID != column.ID && normalizeColumnName(existing.Name) == normalized {
+			return errColumnNameTaken
+		}
+	}
+	return ""
+}
+
+// columnNamed matches columns whose name normalizes to the same as name, the way
+// normalizeColumnName compares them
+func columnNamed(name string) primitive.Regex {
+	const separators = `[^\p{L}\p{N}]*`
+	var b strings.Builder
+	b.WriteString("^" + separators)
+	for _, r := range normalizeColumnName(name) {
+		b.WriteString(regexp.QuoteMeta(string(r)) + separators)
+	}
+	b.WriteString("$")
+	return primitive.Regex{Pattern: b.String(), Options: "i"}
+}
+
+// otherColumnNamed matches boards that have a column other than exceptID with the given
+// name
+func otherColumnNamed(name string, exceptID primitive.ObjectID) bson.M {
+	return bson.M{fieldColumns: bson.M{"$elemMatch": bson.M{"_id": bson.M{"$ne": exceptID}, fieldName: columnNamed(name)}}}
+}
+
+// columnNamedNow matches boards whose column still has the name it was read with
+func columnNamedNow(column models.Column) bson.M {
+	return bson.M{fieldColumns: bson.M{"$elemMatch": bson.M{"_id": column.ID, fieldName: column.Name}}}
+}
+
+// columnConflict explains why a column edit no longer applied, judging by the board as it
+// is now: the column is gone, its new name was taken, or the columns changed otherwise
+func columnConflict(board *models.Board, column models.Column) error {
+	var current models.Board
+	if err := BoardService.FindByID(board.ID, &current); err != nil {
+		return err
+	}
+	if !column.ID.IsZero() {
+		found := false
+		for _, existing := range current.Columns {
+			found = found || existing.ID == column.ID
+		}
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound, errColumnNotFound)
+		}
+	}
+	if msg := validateColumn(&current, column); msg != "" {
+		return fiber.NewError(fiber.StatusBadRequest, msg)
+	}
+	return fiber.NewError(fiber.StatusConflict, errColumnsChanged)
+}
+
+func GetColumns(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	return c.JSON(sortedColumns(board))
+}
+
+func CreateColumn(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ColumnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// New columns are appended after the existing ones
+	column := models.Column{
+		ID:    primitive.NewObjectID(),
+		Name:  strings.TrimSpace(req.Name),
+		Color: req.Color,
+	}
+	for _, existing := range board.Columns {
+		if existing.Order >= column.Order {
+			column.Order = existing.Order + 1
+		}
+	}
+	if msg := validateColumn(board, column); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// The column is pushed only while no column was added meanwhile, which would share
+	// its order, and none took its name
+	filter := bson.M{
+		fieldColumns: bson.M{"$size": len(board.Columns)},
+		"$nor":       []bson.M{otherColumnNamed(column.Name, column.ID)},
+	}
+	ok, err := updateBoardIf(board, filter, bson.M{"$push": bson.M{fieldColumns: column}}, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		column.ID = primitive.NilObjectID
+		return sendError(c, columnConflict(board, column))
+	}
+
+	broadcastColumns(columnActionCreate, board, userObjectID, column.ID, "", sortedColumns(board))
+
+	return c.Status(fiber.StatusCreated).JSON(column)
+}
+
+func UpdateColumn(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	columnID, err := primitive.ObjectIDFromHex(c.Params(paramColumnID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ColumnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	columns := sortedColumns(board)
+	index := -1
+	for i, column := range columns {
+		if column.ID == columnID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errColumnNotFound})
+	}
+
+	current := columns[index]
+	column := models.Column{ID: columnID, Name: strings.TrimSpace(req.Name), Color: req.Color, Order: current.Order}
+	if msg := validateColumn(board, column); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// Only this column is rewritten, and only while it keeps the name its tasks are moved
+	// from and no other column has taken the new one
+	filter := bson.M{"$and": []bson.M{
+		columnNamedNow(current),
+		{"$nor": []bson.M{otherColumnNamed(column.Name, columnID)}},
+	}}
+	update := bson.M{"$set": bson.M{
+		fieldColumns + ".$[column].name":  column.Name,
+		fieldColumns + ".$[column].color": column.Color,
+	}}
+	opts := options.FindOneAndUpdate().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"column._id": columnID}},
+	})
+	ok, err := updateBoardIf(board, filter, update, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return sendError(c, columnConflict(board, column))
+	}
+	oldName := current.Name
+
+	// Tasks reference columns by name, so a rename has to carry them along
+	renamedFrom := ""
+	if oldName != column.Name {
+		renamedFrom = oldName
+		filter := bson.M{fieldBoardID: id, fieldStatus: oldName}
+		update := bson.M{
+			"$set": bson.M{fieldStatus: column.Name, fieldUpdatedAt: time.Now().UTC()},
+			"$inc": bson.M{fieldVersion: 1},
+		}
+		if err := TaskService.UpdateManyRaw(filter, update); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+	}
+
+	broadcastColumns(columnActionUpdate, board, userObjectID, columnID, renamedFrom, sortedColumns(board))
+
+	return c.JSON(column)
+}
+
+func ReorderColumns(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ReorderColumnsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// The new order must name every existing column exactly once
+	if len(req.ColumnIDs) != len(board.Columns) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidColumnOrder})
+	}
+
+	known := make(map[primitive.ObjectID]bool, len(board.Columns))
+	for _, column := range board.Columns {
+		known[column.ID] = true
+	}
+
+	// Only the orders are written, so concurrent renames are kept
+	set := bson.M{}
+	filters := make([]interface{}, 0, len(req.ColumnIDs))
+	for order, columnID := range req.ColumnIDs {
+		if !known[columnID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidColumnOrder})
+		}
+		delete(known, columnID)
+
+		name := "c" + strconv.Itoa(order)
+		set[fieldColumns+".$["+name+"].order"] = order
+		filters = append(filters, bson.M{name + "._id": columnID})
+	}
+
+	// The order applies only while the board has exactly the columns it names
+	filter := bson.M{fieldColumns: bson.M{"$size": len(req.ColumnIDs)}}
+	if len(req.ColumnIDs) > 0 {
+		filter[fieldColumns+"._id"] = bson.M{"$all": req.ColumnIDs}
+	}
+	opts := options.FindOneAndUpdate().SetArrayFilters(options.ArrayFilters{Filters: filters})
+	ok, err := updateBoardIf(board, filter, bson.M{"$set": set}, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errColumnsChanged})
+	}
+
+	columns := sortedColumns(board)
+	broadcastColumns(columnActionReorder, board, userObjectID, primitive.NilObjectID, "", columns)
+
+	return c.JSON(columns)
+}
+
+func DeleteColumn(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	columnID, err := primitive.ObjectIDFromHex(c.Params(paramColumnID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	columns := sortedColumns(board)
+	index := -1
+	for i, column := range columns {
+		if column.ID == columnID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errColumnNotFound})
+	}
+
+	if len(columns) == 1 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errLastColumn})
+	}
+
+	// Refuse to orphan tasks; clients must move them to another column first
+	count, err := TaskService.Count(bson.M{fieldBoardID: id, fieldStatus: columns[index].Name})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errColumnNotEmpty})
+	}
+
+	// The column goes only while it keeps its name and is not the last one left
+	column := columns[index]
+	filter := bson.M{"$and": []bson.M{
+		columnNamedNow(column),
+		{fieldColumns + ".1": bson.M{"$exists": true}},
+	}}
+	pull := bson.M{"$pull": bson.M{fieldColumns: bson.M{"_id": columnID}}}
+	ok, err := updateBoardIf(board, filter, pull, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errColumnsChanged})
+	}
+
+	// A task may have been put in the column after it was counted; then it comes back
+	count, err = TaskService.Count(bson.M{fieldBoardID: id, fieldStatus: column.Name})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if count > 0 {
+		restore := bson.M{"$push": bson.M{fieldColumns: column}}
+		restored, err := updateBoardIf(board, bson.M{"$nor": []bson.M{otherColumnNamed(column.Name, columnID)}}, restore, nil)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+		if !restored {
+			log.Printf("Warning: Column %q of board %s could not be put back for its tasks", column.Name, id.Hex())
+		}
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errColumnNotEmpty})
+	}
+
+	broadcastColumns(columnActionDelete, board, userObjectID, columnID, "", sortedColumns(board))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestColumnNamed(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches []string
+		misses  []string
+	}{
+		{"To Do", []string{"To Do", "todo", "TO_DO", " to-do ", "To.Do"}, []string{"To Dos", "Do To", "o Do"}},
+		{"In (Progress)", []string{"in progress", "InProgress"}, []string{"in progres", "in progress 2"}},
+		{"Qualität", []string{"QUALITÄT", "qualität"}, []string{"qualitat"}},
+		{"v1.2", []string{"V12", "v 1 2"}, []string{"v1.3"}},
+	}
+	for _, tt := range tests {
+		pattern := columnNamed(tt.name)
+		re := regexp.MustCompile("(?" + pattern.Options + ")" + pattern.Pattern)
+		for _, name := range tt.matches {
+			if !re.MatchString(name) {
+				t.Errorf("columnNamed(%q) does not match %q", tt.name, name)
+			}
+			if normalizeColumnName(name) != normalizeColumnName(tt.name) {
+				t.Errorf("test case %q does not normalize like %q", name, tt.name)
+			}
+		}
+		for _, name := range tt.misses {
+			if re.MatchString(name) {
+				t.Errorf("columnNamed(%q) matches %q", tt.name, name)
+			}
+		}
+	}
+}
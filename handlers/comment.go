@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxCommentLength caps the length of a comment, in characters
+const maxCommentLength = 10000
+
+// validateCommentText trims a comment and checks its length
+func validateCommentText(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errCommentTextRequired
+	}
+	if utf8.RuneCountInString(text) > maxCommentLength {
+		return "", errCommentTooLong
+	}
+	return text, ""
+}
+
+// findTaskComment loads a comment of the task named by the commentId route parameter
+func findTaskComment(c *fiber.Ctx, task *models.Task) (*models.Comment, error) {
+	commentID, err := primitive.ObjectIDFromHex(c.Params(paramCommentID))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+	}
+
+	var comment models.Comment
+	if err := CommentService.FindOne(bson.M{"_id": commentID, fieldTaskID: task.ID}, &comment); err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, errCommentNotFound)
+	}
+	return &comment, nil
+}
+
+// broadcastComment tells the board's clients about a change to one of the task's comments
+func broadcastComment(action string, board *models.Board, task *models.Task, userID primitive.ObjectID, comment *models.Comment) {
+	BroadcastTaskChange(messageTypeComment, board, task, userID, CommentEvent{Action: action, Comment: comment})
+}
+
+func GetComments(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	if _, _, err := authorizeTask(id, userObjectID, models.RoleViewer); err != nil {
+		return sendError(c, err)
+	}
+
+	// Oldest first, the way a thread reads
+	comments := []models.Comment{}
+	opts := options.Find().SetSort(bson.D{{Key: fieldCreatedAt, Value: 1}, {Key: "_id", Value: 1}})
+	if err := CommentService.FindWithOptions(bson.M{fieldTaskID: id}, opts, &comments); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(comments)
+}
+
+func CreateComment(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	text, msg := validateCommentText(req.Text)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	comment := models.Comment{
+		TaskID:    id,
+		BoardID:   task.BoardID,
+		UserID:    userObjectID,
+		Text:      text,
+		CreatedAt: time.Now().UTC(),
+	}
+	if comment.ID, err = CommentService.InsertOne(comment); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	broadcastComment(commentActionCreate, board, task, userObjectID, &comment)
+
+	notifyMentions(board, task, userObjectID, "", comment.Text, &comment.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+func UpdateComment(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	// Authors keep the right to fix their comments while they can read the board
+	task, board, err := authorizeTask(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	comment, err := findTaskComment(c, task)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if comment.UserID != userObjectID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errNotCommentAuthor})
+	}
+
+	var req CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	text, msg := validateCommentText(req.Text)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// Saving the same text again is not an edit
+	if text == comment.Text {
+		return c.JSON(comment)
+	}
+
+	editedAt := time.Now().UTC()
+	if err := CommentService.UpdateByID(comment.ID, bson.M{fieldText: text, fieldEditedAt: editedAt}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	previousText := comment.Text
+	comment.Text = text
+	comment.EditedAt = &editedAt
+
+	broadcastComment(commentActionUpdate, board, task, userObjectID, comment)
+
+	notifyMentions(board, task, userObjectID, previousText, comment.Text, &comment.ID)
+
+	return c.JSON(comment)
+}
+
+func DeleteComment(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	comment, err := findTaskComment(c, task)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	// Authors may delete their own comments; the board owner may delete any
+	if comment.UserID != userObjectID && !hasRole(board, userObjectID, models.RoleOwner) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errNotCommentAuthor})
+	}
+
+	if err := CommentService.DeleteByID(comment.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	broadcastComment(commentActionDelete, board, task, userObjectID, comment)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// taskETag formats a task version as a strong entity tag
+func taskETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setTaskETag exposes the task's version to HTTP clients
+func setTaskETag(c *fiber.Ctx, task *models.Task) {
+	c.Set(fiber.HeaderETag, taskETag(task.Version))
+}
+
+// checkIfMatch compares the request's If-Match header with the task's current version.
+// Requests without the header are not conditional and always pass.
+func checkIfMatch(c *fiber.Ctx, task *models.Task) error {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	current := taskETag(task.Version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == current {
+			return nil
+		}
+	}
+	return fiber.NewError(fiber.StatusPreconditionFailed, errVersionMismatch)
+}
+
+// versionFilter matches a task that is still at the given version. Tasks created before
+// versioning have no version field and count as version 0.
+func versionFilter(task *models.Task) bson.M {
+	if task.Version == 0 {
+		return bson.M{"_id": task.ID, fieldVersion: bson.M{"$in": bson.A{0, nil}}}
+	}
+	return bson.M{"_id": task.ID, fieldVersion: task.Version}
+}
+
+// updateTaskVersion applies the update only if nobody changed the task since it was read,
+// bumping its version and updatedAt. On success task is replaced by the stored document.
+func updateTaskVersion(task *models.Task, update bson.M) error {
+	update[fieldUpdatedAt] = time.Now().UTC()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := TaskService.FindOneAndUpdate(versionFilter(task), bson.M{
+		"$set": update,
+		"$inc": bson.M{fieldVersion: 1},
+	}, opts, task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fiber.NewError(fiber.StatusPreconditionFailed, errVersionMismatch)
+	}
+	return err
+}
+
+// deleteTaskVersion deletes the task only if nobody changed it since it was read
+func deleteTaskVersion(task *models.Task) error {
+	deleted, err := TaskService.DeleteOne(versionFilter(task))
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fiber.NewError(fiber.StatusPreconditionFailed, errVersionMismatch)
+	}
+	return nil
+}
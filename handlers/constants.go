@@ -3,13 +3,29 @@ package handlers
 import "github.com/AttFlederX/kanban_board_server/services"
 
 var (
-	TaskService = services.NewMongoService("tasks")
-	UserService = services.NewMongoService("users")
+	TaskService  = services.NewMongoService("tasks")
+	UserService  = services.NewMongoService("users")
+	BoardService = services.NewMongoService("boards")
+	EventService = services.NewMongoService("events")
+
+	TombstoneService = services.NewMongoService("task_tombstones")
+	FilterService    = services.NewMongoService("filters")
+	CommentService   = services.NewMongoService("comments")
+
+	NotificationService = services.NewMongoService("notifications")
+	AttachmentService   = services.NewMongoService("attachments")
+	ActivityService     = services.NewMongoService("activity")
+	TrashService        = services.NewMongoService("task_trash")
+
+	ReminderService           = services.NewMongoService("reminders")
+	ReminderPreferenceService = services.NewMongoService("reminder_preferences")
 )
 
 const (
 	// Context keys
-	contextKeyUserID = "userID"
+	contextKeyUserID         = "userID"
+	contextKeyTokenID        = "tokenID"
+	contextKeyTokenExpiresAt = "tokenExpiresAt"
 
 	// BSON field names
 	fieldName        = "name"
@@ -19,10 +35,149 @@ const (
 	fieldPhotoURL    = "photourl"
 	fieldGoogleID    = "google_id"
 	fieldEmail       = "email"
+	fieldBoardID     = "boardId"
+	fieldOwnerID     = "ownerId"
+	fieldColumns     = "columns"
+	fieldRank        = "rank"
+	fieldMembers     = "members"
+	fieldEventSeq    = "eventSeq"
+	fieldSeq         = "seq"
+	fieldCreatedAt   = "createdAt"
+	fieldUpdatedAt   = "updatedAt"
+	fieldDeletedAt   = "deletedAt"
+	fieldVersion     = "version"
+	fieldQuery       = "query"
+	fieldStartDate   = "startDate"
+	fieldDueDate     = "dueDate"
+	fieldTimeZone    = "timeZone"
+	fieldOverdue     = "overdue"
+	fieldDueSoon     = "dueSoon"
+	fieldTaskID      = "taskId"
+	fieldLeadMinutes = "leadMinutes"
+	fieldSentAt      = "sentAt"
+	fieldEnabled     = "enabled"
+	fieldLabels      = "labels"
+	fieldAssignees   = "assignees"
+	fieldChecklist   = "checklist"
+	fieldText        = "text"
+	fieldEditedAt    = "editedAt"
+	fieldRead        = "read"
+	fieldAction      = "action"
+	fieldUndone      = "undone"
+	fieldArchived    = "archived"
+	fieldArchivedAt  = "archivedAt"
+
+	fieldChecklistProgress = "checklistProgress"
+
+	// BSON paths into embedded documents
+	fieldMembersUserID = "members.userId"
 
 	// JSON field names
-	jsonFieldID      = "id"
-	jsonFieldError   = "error"
+	jsonFieldID       = "id"
+	jsonFieldError    = "error"
+	jsonFieldFields   = "fields"
+	jsonFieldPosition = "position"
+
+	// Route parameter names
+	paramColumnID     = "columnId"
+	paramUserID       = "userId"
+	paramFilterID     = "filterId"
+	paramLabelID      = "labelId"
+	paramItemID       = "itemId"
+	paramCommentID    = "commentId"
+	paramAttachmentID = "attachmentId"
+
+	// Query parameter names
+	queryBoardID     = "boardId"
+	querySince       = "since"
+	queryStatus      = "status"
+	queryText        = "q"
+	querySort        = "sort"
+	queryLimit       = "limit"
+	queryCursor      = "cursor"
+	queryExpression  = "query"
+	queryFilterID    = "filter"
+	queryTimeZone    = "tz"
+	queryOverdue     = "overdue"
+	queryDueSoon     = "dueSoon"
+	queryDueBefore   = "dueBefore"
+	queryDueAfter    = "dueAfter"
+	queryStartBefore = "startBefore"
+	queryStartAfter  = "startAfter"
+	queryLabel       = "label"
+	queryAssignee    = "assignee"
+	queryUnread      = "unread"
+	queryBefore      = "before"
+	queryActor       = "userId"
+	queryArchived    = "archived"
+	queryExpires     = "expires"
+	querySignature   = "signature"
+
+	// Websocket message types
+	messageTypeCreate         = "create"
+	messageTypeUpdate         = "update"
+	messageTypeDelete         = "delete"
+	messageTypePatch          = "patch"
+	messageTypeSubscribed     = "subscribed"
+	messageTypeUnsubscribed   = "unsubscribed"
+	messageTypeError          = "error"
+	messageTypeResyncRequired = "resync_required"
+	messageTypeReminder       = "reminder"
+	messageTypeChecklist      = "checklist"
+	messageTypeComment        = "comment"
+	messageTypeNotification   = "notification"
+	messageTypeAttachment     = "attachment"
+	messageTypeColumns        = "columns"
+	messageTypeLabels         = "labels"
+	messageTypeMembers        = "members"
+
+	// Checklist changes reported in checklist messages
+	checklistActionAdd     = "add"
+	checklistActionUpdate  = "update"
+	checklistActionReorder = "reorder"
+	checklistActionRemove  = "remove"
+
+	// Comment changes reported in comment messages
+	commentActionCreate = "create"
+	commentActionUpdate = "update"
+	commentActionDelete = "delete"
+
+	// Attachment changes reported in attachment messages
+	attachmentActionCreate = "create"
+	attachmentActionDelete = "delete"
+
+	// Column changes reported in columns messages
+	columnActionCreate  = "create"
+	columnActionUpdate  = "update"
+	columnActionReorder = "reorder"
+	columnActionDelete  = "delete"
+
+	// Label changes reported in labels messages
+	labelActionCreate = "create"
+	labelActionUpdate = "update"
+	labelActionDelete = "delete"
+
+	// Membership changes reported in members messages
+	memberActionAdd    = "add"
+	memberActionUpdate = "update"
+	memberActionRemove = "remove"
+
+	// Multipart form field names
+	formFieldFile = "file"
+
+	// Patch content types
+	mimeMergePatch = "application/merge-patch+json"
+	mimeJSONPatch  = "application/json-patch+json"
+
+	// Websocket client actions
+	clientActionSubscribe   = "subscribe"
+	clientActionUnsubscribe = "unsubscribe"
+
+	// Websocket close reasons
+	closeReasonSlowConsumer = "Client is not keeping up with updates"
+	closeReasonTokenExpired = "Token expired"
+	closeReasonTokenRevoked = "Token revoked"
+	closeReasonIdleTimeout  = "Connection idle for too long"
 
 	// Token payload claim keys
 	claimEmail   = "email"
@@ -30,15 +185,100 @@ const (
 	claimPicture = "picture"
 
 	// Error messages
-	errInvalidUserID       = "Invalid user ID"
-	errInvalidID           = "Invalid ID"
-	errTaskNotFound        = "Task not found"
-	errUserNotFound        = "User not found"
-	errAccessDenied        = "Access denied"
-	errInvalidRequestBody  = "Invalid request body"
-	errIDTokenRequired     = "ID token is required"
-	errInvalidGoogleToken  = "Invalid Google ID token"
-	errFailedCreateUser    = "Failed to create user"
-	errFailedUpdateUser    = "Failed to update user"
-	errFailedGenerateToken = "Failed to generate token"
+	errInvalidUserID            = "Invalid user ID"
+	errInvalidID                = "Invalid ID"
+	errInvalidBoardID           = "Invalid board ID"
+	errBoardIDRequired          = "Board ID is required"
+	errBoardNameRequired        = "Board name is required"
+	errInvalidStatus            = "Status does not match any column of the board"
+	errColumnNameRequired       = "Column name is required"
+	errColumnNameTaken          = "A column with this name already exists"
+	errInvalidColumnColor       = "Column color must be a hex value like #1E88E5"
+	errInvalidColumnOrder       = "Column order must list every column of the board exactly once"
+	errColumnNotEmpty           = "Column still contains tasks"
+	errLastColumn               = "A board must keep at least one column"
+	errColumnsChanged           = "The board's columns changed meanwhile; reload them and try again"
+	errInvalidMoveAnchor        = "Move anchors must be tasks in the target column"
+	errInvalidRole              = "Role must be editor or viewer"
+	errMemberRequired           = "User ID or email is required"
+	errAlreadyMember            = "User is already a member of the board"
+	errOwnerRoleFixed           = "The board owner cannot be changed or removed"
+	errInvalidLimit             = "Limit must be a number between 1 and 500"
+	errInvalidSort              = "Unknown sort key"
+	errSearchQueryRequired      = "Search query is required"
+	errInvalidSearchLimit       = "Limit must be a number between 1 and 100"
+	errInvalidPageCursor        = "Invalid page cursor"
+	errInvalidSyncCursor        = "Invalid sync cursor"
+	errInvalidPatch             = "Patch contains invalid fields"
+	errUnsupportedPatchType     = "Patch must be application/merge-patch+json or application/json-patch+json"
+	errFieldNotPatchable        = "Field cannot be changed"
+	errPatchExpectedString      = "Value must be a string"
+	errPatchInvalidPath         = "Patch path must name a single task field"
+	errPatchInvalidOp           = "Unsupported patch operation"
+	errPatchValueRequired       = "Patch operation requires a value"
+	errPatchTestFailed          = "Patch test failed"
+	errPatchExpectedIDs         = "Value must be a list of IDs, or null"
+	errPatchExpectedDate        = "Value must be an RFC 3339 timestamp with a time zone offset, or null"
+	errInvalidTimeZone          = "Time zone must be an IANA name like Europe/Berlin"
+	errStartAfterDue            = "Start date must not be after the due date"
+	errInvalidFlag              = "Flag must be true or false"
+	errTaskNameRequired         = "Task name is required"
+	errInvalidLeadMinutes       = "Lead times must be distinct numbers of minutes between 0 and 10080"
+	errTooManyLeadTimes         = "At most 5 reminder lead times are allowed"
+	errVersionMismatch          = "Task was changed by someone else; reload it and try again"
+	errFilterNameRequired       = "Filter name is required"
+	errFilterNameTaken          = "A filter with this name already exists"
+	errFilterNotFound           = "Filter not found"
+	errLabelNameRequired        = "Label name is required"
+	errLabelNameTaken           = "A label with this name already exists"
+	errInvalidLabelColor        = "Label color must be a hex value like #1E88E5"
+	errLabelNotFound            = "Label not found"
+	errAssigneeNotMember        = "Assignees must be members of the board"
+	errAssigneeNotFound         = "User is not assigned to the task"
+	errChecklistTextRequired    = "Checklist item text is required"
+	errChecklistFull            = "A checklist holds at most 100 items"
+	errChecklistItemNotFound    = "Checklist item not found"
+	errInvalidChecklistOrder    = "Checklist order must list every item exactly once"
+	errCommentTextRequired      = "Comment text is required"
+	errCommentTooLong           = "Comments are limited to 10000 characters"
+	errCommentNotFound          = "Comment not found"
+	errNotCommentAuthor         = "Only the author can change this comment"
+	errNotificationNotFound     = "Notification not found"
+	errReadRequired             = "Read flag is required"
+	errInvalidFeedLimit         = "Limit must be a number between 1 and 200"
+	errAttachmentFileRequired   = "Attachment must be sent as the file field of a multipart form"
+	errAttachmentEmpty          = "Attachment is empty"
+	errAttachmentTooLarge       = "Attachment exceeds the size limit"
+	errAttachmentTypeNotAllowed = "Attachments of this type are not allowed"
+	errAttachmentNotFound       = "Attachment not found"
+	errInvalidAttachmentURL     = "Invalid attachment download link"
+	errAttachmentURLExpired     = "Attachment download link has expired"
+	errTrashedTaskNotFound      = "Task is not in the trash"
+	errNothingToUndo            = "Nothing to undo"
+	errInvalidArchivedFilter    = "Archived must be true, false or all"
+	errInvalidArchiveAge        = "olderThanDays must be a number of days, 0 or more"
+	errUndoConflict             = "The task was changed since; this action can no longer be undone"
+	errUnknownLabel             = "Labels must come from the board's label catalogue"
+	errTaskNotFound             = "Task not found"
+	errColumnNotFound           = "Column not found"
+	errMemberNotFound           = "Member not found"
+	errBoardNotFound            = "Board not found"
+	errUserNotFound             = "User not found"
+	errAccessDenied             = "Access denied"
+	errInvalidRequestBody       = "Invalid request body"
+	errIDTokenRequired          = "ID token is required"
+	errInvalidGoogleToken       = "Invalid Google ID token"
+	errFailedCreateUser         = "Failed to create user"
+	errFailedUpdateUser         = "Failed to update user"
+	errFailedGenerateToken      = "Failed to generate token"
+	errUnknownAction            = "Unknown action"
+	errTokenNotRevocable        = "Token cannot be revoked"
+	errFailedRevokeToken        = "Failed to revoke token"
+)
+
+// Websocket close codes, taken from the range RFC 6455 reserves for applications
+const (
+	closeCodeTokenExpired = 4001
+	closeCodeTokenRevoked = 4003
+	closeCodeIdleTimeout  = 4008
 )
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/query"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DueSoonWindow is how far ahead of its due date an unfinished task counts as due soon
+var DueSoonWindow = 48 * time.Hour
+
+// SetDueSoonWindow sets how far ahead of its due date a task counts as due soon
+func SetDueSoonWindow(window time.Duration) {
+	DueSoonWindow = window
+}
+
+// doneStatus returns the name of the board's last column. Tasks there are finished and
+// are never overdue.
+func doneStatus(board *models.Board) string {
+	columns := sortedColumns(board)
+	if len(columns) == 0 {
+		return ""
+	}
+	return columns[len(columns)-1].Name
+}
+
+// loadTimeZone resolves an IANA time zone name. The server's own zone is not accepted,
+// since it means something different on every deployment.
+func loadTimeZone(name string) (*time.Location, bool) {
+	if name == "" {
+		return time.UTC, true
+	}
+	if name == "Local" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	return loc, err == nil
+}
+
+// normalizeTaskDates validates the task's dates and time zone and stores the dates in UTC,
+// at the millisecond precision Mongo keeps. It returns the JSON name of the offending field
+// and an error message when they are not valid.
+func normalizeTaskDates(task *models.Task) (string, string) {
+	if _, ok := loadTimeZone(task.TimeZone); !ok {
+		return fieldTimeZone, errInvalidTimeZone
+	}
+
+	normalize := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		utc := t.UTC().Truncate(time.Millisecond)
+		return &utc
+	}
+	task.StartDate = normalize(task.StartDate)
+	task.DueDate = normalize(task.DueDate)
+
+	if task.StartDate != nil && task.DueDate != nil && task.StartDate.After(*task.DueDate) {
+		return fieldDueDate, errStartAfterDue
+	}
+	return "", ""
+}
+
+// setDueFlags computes whether the board's tasks are overdue or due soon
+func setDueFlags(board *models.Board, tasks ...*models.Task) {
+	now := time.Now()
+	done := doneStatus(board)
+	for _, task := range tasks {
+		task.Overdue, task.DueSoon = false, false
+		if task.DueDate == nil || task.Status == done {
+			continue
+		}
+		task.Overdue = task.DueDate.Before(now)
+		task.DueSoon = !task.Overdue && task.DueDate.Before(now.Add(DueSoonWindow))
+	}
+}
+
+// setDueFlagsAll computes the due flags of tasks spread over several boards
+func setDueFlagsAll(tasks []models.Task, boards []models.Board) {
+	byID := make(map[primitive.ObjectID]*models.Board, len(boards))
+	for i := range boards {
+		byID[boards[i].ID] = &boards[i]
+	}
+	for i := range tasks {
+		if board, ok := byID[tasks[i].BoardID]; ok {
+			setDueFlags(board, &tasks[i])
+		}
+	}
+}
+
+// doneTasksFilter matches the finished tasks of the boards
+func doneTasksFilter(boards []models.Board) bson.M {
+	done := make([]bson.M, 0, len(boards))
+	for i := range boards {
+		done = append(done, bson.M{fieldBoardID: boards[i].ID, fieldStatus: doneStatus(&boards[i])})
+	}
+	if len(done) == 0 {
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": done}
+}
+
+// overdueFilter matches unfinished tasks whose due date has passed
+func overdueFilter(boards []models.Board, now time.Time) bson.M {
+	return bson.M{
+		fieldDueDate: bson.M{"$lt": now},
+		"$nor":       []bson.M{doneTasksFilter(boards)},
+	}
+}
+
+// dueSoonFilter matches unfinished tasks that fall due within DueSoonWindow
+func dueSoonFilter(boards []models.Board, now time.Time) bson.M {
+	return bson.M{
+		fieldDueDate: bson.M{"$gte": now, "$lt": now.Add(DueSoonWindow)},
+		"$nor":       []bson.M{doneTasksFilter(boards)},
+	}
+}
+
+// requestLocation returns the time zone named by the tz query parameter, UTC by default
+func requestLocation(c *fiber.Ctx) (*time.Location, error) {
+	loc, ok := loadTimeZone(c.Query(queryTimeZone))
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidTimeZone)
+	}
+	return loc, nil
+}
+
+// flagQueryFilter keeps tasks for which the flag query parameter's condition holds or,
+// when it is false, does not hold
+func flagQueryFilter(param string, condition func(boards []models.Board, now time.Time) bson.M) taskQueryFilter {
+	return func(c *fiber.Ctx, boards []models.Board) (bson.M, error) {
+		value := c.Query(param)
+		if value == "" {
+			return nil, nil
+		}
+		want, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidFlag+": "+param)
+		}
+
+		filter := condition(boards, time.Now())
+		if !want {
+			return bson.M{"$nor": []bson.M{filter}}, nil
+		}
+		return filter, nil
+	}
+}
+
+// dateRangeQueryFilter keeps tasks whose date field lies before and/or after the times
+// given in the query parameters. Dates without a time are taken in the tz time zone.
+func dateRangeQueryFilter(field, beforeParam, afterParam string) taskQueryFilter {
+	return func(c *fiber.Ctx, _ []models.Board) (bson.M, error) {
+		before, after := c.Query(beforeParam), c.Query(afterParam)
+		if before == "" && after == "" {
+			return nil, nil
+		}
+
+		loc, err := requestLocation(c)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		condition := bson.M{}
+		for op, param := range map[string]string{"$lt": beforeParam, "$gt": afterParam} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			t, err := query.ParseTime(value, now, loc)
+			if err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, param+": "+err.Error())
+			}
+			condition[op] = t
+		}
+		return bson.M{field: condition}, nil
+	}
+}
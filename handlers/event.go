@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/contrib/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// boardLocks holds a *sync.Mutex per board. Sequence numbers are assigned and events
+// handed to the hub under the board's lock so that clients receive them in order.
+var boardLocks sync.Map
+
+// lockBoard locks the board's event stream and returns the matching unlock function
+func lockBoard(boardID primitive.ObjectID) func() {
+	lock, _ := boardLocks.LoadOrStore(boardID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// nextEventSeq atomically advances the board's event sequence and returns the new value
+func nextEventSeq(boardID primitive.ObjectID) (int64, error) {
+	var board models.Board
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$inc": bson.M{fieldEventSeq: 1}}
+	if err := BoardService.FindOneAndUpdate(bson.M{"_id": boardID}, update, opts, &board); err != nil {
+		return 0, err
+	}
+	return board.EventSeq, nil
+}
+
+// recordEvent assigns the message the board's next sequence number and appends it to the
+// board's event log, trimming the log to the configured size. Callers must hold the board lock.
+func recordEvent(boardID primitive.ObjectID, message *Message) error {
+	seq, err := nextEventSeq(boardID)
+	if err != nil {
+		return err
+	}
+	message.Seq = seq
+
+	data, err := json.Marshal(message.Data)
+	if err != nil {
+		return err
+	}
+
+	event := models.Event{
+		BoardID:   boardID,
+		Seq:       seq,
+		Type:      message.Type,
+		TaskID:    message.TaskID,
+		Version:   message.Version,
+		UserID:    message.UserID,
+		Data:      string(data),
+		CreatedAt: time.Now().UTC(),
+	}
+	if _, err := EventService.InsertOne(event); err != nil {
+		return err
+	}
+
+	return EventService.DeleteMany(bson.M{
+		fieldBoardID: boardID,
+		fieldSeq:     bson.M{"$lte": seq - int64(hub.config.EventLogSize)},
+	})
+}
+
+// replayEvents queues the events a client missed since the given sequence number.
+// When the log no longer reaches back that far, or the backlog would not fit in the
+// free part of the client's queue, the client is told to resync instead. Clients whose
+// queue fills up during the replay anyway are dropped, like slow clients during a
+// broadcast, so that they never miss events silently. Callers must hold the board lock.
+func replayEvents(client *Client, board *models.Board, since int64) error {
+	boardID := board.ID
+	resync := Message{Type: messageTypeResyncRequired, BoardID: boardID.Hex(), Seq: board.EventSeq}
+	if since > board.EventSeq {
+		client.enqueue(resync)
+		return nil
+	}
+	if since == board.EventSeq {
+		return nil
+	}
+
+	events := []models.Event{}
+	filter := bson.M{fieldBoardID: boardID, fieldSeq: bson.M{"$gt": since}}
+	opts := options.Find().SetSort(bson.D{{Key: fieldSeq, Value: 1}})
+	if err := EventService.FindWithOptions(filter, opts, &events); err != nil {
+		return err
+	}
+
+	// One slot is kept free for a resync_required message
+	if len(events) == 0 || events[0].Seq != since+1 || len(events) >= cap(client.send)-len(client.send) {
+		client.enqueue(resync)
+		return nil
+	}
+
+	for _, event := range events {
+		queued := client.enqueue(Message{
+			Type:    event.Type,
+			Seq:     event.Seq,
+			TaskID:  event.TaskID,
+			Version: event.Version,
+			BoardID: boardID.Hex(),
+			UserID:  event.UserID,
+			Data:    json.RawMessage(event.Data),
+		})
+		if !queued {
+			log.Printf("Dropping slow client of user %s during replay", client.UserID.Hex())
+			hub.removeClient(client, websocket.CloseTryAgainLater, closeReasonSlowConsumer)
+			return nil
+		}
+	}
+	return nil
+}
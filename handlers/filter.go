@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/query"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxTaskQueryLength caps the length of task queries, given directly or saved in a filter
+const maxTaskQueryLength = 2048
+
+// queryContext holds what compiling a task query depends on
+type queryContext struct {
+	userID primitive.ObjectID
+	boards []models.Board
+	now    time.Time
+	loc    *time.Location
+}
+
+// queryTermCompiler compiles one key of the task query language
+type queryTermCompiler func(ctx *queryContext, term *query.Term) (bson.M, error)
+
+// queryTermCompilers lists the keys of the task query language. Free text has the empty key.
+var queryTermCompilers = map[string]queryTermCompiler{
+	"":        textTerm,
+	"text":    textTerm,
+	"status":  statusTerm,
+	"creator": creatorTerm,
+	"created": func(ctx *queryContext, term *query.Term) (bson.M, error) {
+		return dateTerm(ctx, term, fieldCreatedAt)
+	},
+	"updated": func(ctx *queryContext, term *query.Term) (bson.M, error) {
+		return dateTerm(ctx, term, fieldUpdatedAt)
+	},
+	"start": func(ctx *queryContext, term *query.Term) (bson.M, error) {
+		return dateTerm(ctx, term, fieldStartDate)
+	},
+	"due": func(ctx *queryContext, term *query.Term) (bson.M, error) {
+		return dateTerm(ctx, term, fieldDueDate)
+	},
+	"is":       isTerm,
+	"label":    labelTerm,
+	"assignee": assigneeTerm,
+}
+
+// noComparison rejects comparison operators on keys that only match values
+func noComparison(term *query.Term) error {
+	if term.Op != "" {
+		return &query.Error{Pos: term.ValuePos - len(term.Op), Msg: fmt.Sprintf("%q does not support %s", term.Key, term.Op)}
+	}
+	return nil
+}
+
+// textTerm matches tasks whose name or description contains the text
+func textTerm(_ *queryContext, term *query.Term) (bson.M, error) {
+	if err := noComparison(term); err != nil {
+		return nil, err
+	}
+	return containsTextFilter(term.Value), nil
+}
+
+// statusTerm matches tasks in the named column of any of the boards
+func statusTerm(ctx *queryContext, term *query.Term) (bson.M, error) {
+	if err := noComparison(term); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for i := range ctx.boards {
+		if name, ok := resolveStatus(&ctx.boards[i], term.Value); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no column named %q", term.Value)
+	}
+	return bson.M{fieldStatus: bson.M{"$in": names}}, nil
+}
+
+// labelTerm matches tasks with the label of the given name or ID on any of the boards
+func labelTerm(ctx *queryContext, term *query.Term) (bson.M, error) {
+	if err := noComparison(term); err != nil {
+		return nil, err
+	}
+
+	ids := labelIDs(ctx.boards, term.Value)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no label named %q", term.Value)
+	}
+	return bson.M{fieldLabels: bson.M{"$in": ids}}, nil
+}
+
+// queryUserID resolves "me" or a user ID given in a query
+func queryUserID(ctx *queryContext, value string) (primitive.ObjectID, error) {
+	if strings.EqualFold(value, "me") {
+		return ctx.userID, nil
+	}
+	userID, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("expected me or a user ID, found %q", value)
+	}
+	return userID, nil
+}
+
+// creatorTerm matches tasks created by the given user
+func creatorTerm(ctx *queryContext, term *query.Term) (bson.M, error) {
+	if err := noComparison(term); err != nil {
+		return nil, err
+	}
+	userID, err := queryUserID(ctx, term.Value)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{fieldUserID: userID}, nil
+}
+
+// assigneeTerm matches tasks assigned to the given user, or unassigned tasks for none
+func assigneeTerm(ctx *queryContext, term *query.Term) (bson.M, error) {
+	if err := noComparison(term); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(term.Value, "none") {
+		return unassignedFilter(), nil
+	}
+	userID, err := queryUserID(ctx, term.Value)
+	if err != nil {
+		return nil, err
+	}
+	return assignedToFilter(userID), nil
+}
+
+// isTerm matches tasks by a computed state such as is:overdue
+func isTerm(ctx *queryContext, term *query.Term) (bson.M, error) {
+	if err := noComparison(term); err != nil {
+		return nil, err
+	}
+	switch normalizeColumnName(term.Value) {
+	case "overdue":
+		return overdueFilter(ctx.boards, ctx.now), nil
+	case "duesoon":
+		return dueSoonFilter(ctx.boards, ctx.now), nil
+	}
+	return nil, fmt.Errorf("unknown state %q; use overdue or dueSoon", term.Value)
+}
+
+// dateTerm compares a date field. Without an operator it matches the whole day.
+func dateTerm(ctx *queryContext, term *query.Term, field string) (bson.M, error) {
+	t, err := query.ParseTime(term.Value, ctx.now, ctx.loc)
+	if err != nil {
+		return nil, err
+	}
+	if term.Op == "" {
+		t = t.In(ctx.loc)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, ctx.loc)
+		return bson.M{field: bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}}, nil
+	}
+	return bson.M{field: bson.M{query.MongoOp(term.Op): t}}, nil
+}
+
+// compileTaskQuery parses a task query and compiles it into a Mongo filter
+func compileTaskQuery(ctx *queryContext, input string) (bson.M, error) {
+	if len(input) > maxTaskQueryLength {
+		return nil, &query.Error{Pos: maxTaskQueryLength, Msg: fmt.Sprintf("query is longer than %d bytes", maxTaskQueryLength)}
+	}
+	node, err := query.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return query.Compile(node, func(term *query.Term) (bson.M, error) {
+		compile, ok := queryTermCompilers[term.Key]
+		if !ok {
+			return nil, &query.Error{Pos: term.Pos, Msg: fmt.Sprintf("unknown field %q", term.Key)}
+		}
+		return compile(ctx, term)
+	})
+}
+
+// newQueryContext prepares compiling queries for the user over the given boards, reading
+// dates without a time in the given time zone
+func newQueryContext(userID primitive.ObjectID, boards []models.Board, loc *time.Location) *queryContext {
+	return &queryContext{userID: userID, boards: boards, now: time.Now(), loc: loc}
+}
+
+// expressionQueryFilter applies a task query given directly, by the query parameter, or
+// by the ID of a saved filter, which also limits the listing to the filter's board
+func expressionQueryFilter(c *fiber.Ctx, boards []models.Board) (bson.M, error) {
+	input := c.Query(queryExpression)
+	filterID := c.Query(queryFilterID)
+	if input == "" && filterID == "" {
+		return nil, nil
+	}
+
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := requestLocation(c)
+	if err != nil {
+		return nil, err
+	}
+	ctx := newQueryContext(userObjectID, boards, loc)
+
+	var conditions []bson.M
+	if filterID != "" {
+		filterObjectID, err := primitive.ObjectIDFromHex(filterID)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+		}
+
+		var saved models.SavedFilter
+		if err := FilterService.FindByID(filterObjectID, &saved); err != nil || saved.UserID != userObjectID {
+			return nil, fiber.NewError(fiber.StatusNotFound, errFilterNotFound)
+		}
+
+		filter, err := compileTaskQuery(ctx, saved.Query)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, bson.M{fieldBoardID: saved.BoardID}, filter)
+	}
+
+	if input != "" {
+		filter, err := compileTaskQuery(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, filter)
+	}
+	return bson.M{"$and": conditions}, nil
+}
+
+// findSavedFilter loads one of the user's saved filters of the board
+func findSavedFilter(c *fiber.Ctx, boardID, userID primitive.ObjectID) (*models.SavedFilter, error) {
+	filterID, err := primitive.ObjectIDFromHex(c.Params(paramFilterID))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+	}
+
+	var saved models.SavedFilter
+	filter := bson.M{"_id": filterID, fieldBoardID: boardID, fieldUserID: userID}
+	if err := FilterService.FindOne(filter, &saved); err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, errFilterNotFound)
+	}
+	return &saved, nil
+}
+
+// validateSavedFilter checks a filter's name and query, returning the cleaned-up name
+func validateSavedFilter(board *models.Board, userID primitive.ObjectID, filterID primitive.ObjectID, req *SavedFilterRequest) (string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, errFilterNameRequired)
+	}
+
+	ctx := newQueryContext(userID, []models.Board{*board}, time.UTC)
+	if _, err := compileTaskQuery(ctx, req.Query); err != nil {
+		return "", err
+	}
+
+	// Names are unique among the user's filters of the board
+	var existing models.SavedFilter
+	err := FilterService.FindOne(bson.M{fieldBoardID: board.ID, fieldUserID: userID, fieldName: name}, &existing)
+	if err == nil && existing.ID != filterID {
+		return "", fiber.NewError(fiber.StatusConflict, errFilterNameTaken)
+	}
+	return name, nil
+}
+
+func GetFilters(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	if _, err := authorizeBoard(id, userObjectID, models.RoleViewer); err != nil {
+		return sendError(c, err)
+	}
+
+	filters := []models.SavedFilter{}
+	if err := FilterService.Find(bson.M{fieldBoardID: id, fieldUserID: userObjectID}, &filters); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	return c.JSON(filters)
+}
+
+func CreateFilter(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	// Anyone who can read the board may keep filters of their own for it
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req SavedFilterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	name, err := validateSavedFilter(board, userObjectID, primitive.NilObjectID, &req)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	saved := models.SavedFilter{
+		BoardID:   id,
+		UserID:    userObjectID,
+		Name:      name,
+		Query:     req.Query,
+		CreatedAt: time.Now().UTC(),
+	}
+	saved.UpdatedAt = saved.CreatedAt
+
+	if saved.ID, err = FilterService.InsertOne(saved); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(saved)
+}
+
+func UpdateFilter(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	saved, err := findSavedFilter(c, id, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req SavedFilterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	name, err := validateSavedFilter(board, userObjectID, saved.ID, &req)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	saved.Name = name
+	saved.Query = req.Query
+	saved.UpdatedAt = time.Now().UTC()
+	update := bson.M{fieldName: saved.Name, fieldQuery: saved.Query, fieldUpdatedAt: saved.UpdatedAt}
+	if err := FilterService.UpdateByID(saved.ID, update); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(saved)
+}
+
+func DeleteFilter(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	if _, err := authorizeBoard(id, userObjectID, models.RoleViewer); err != nil {
+		return sendError(c, err)
+	}
+
+	saved, err := findSavedFilter(c, id, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := FilterService.DeleteByID(saved.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// queryErrorResponse reports a task query error together with its position
+func queryErrorResponse(err error) (fiber.Map, bool) {
+	var queryErr *query.Error
+	if !errors.As(err, &queryErr) {
+		return nil, false
+	}
+	return fiber.Map{jsonFieldError: queryErr.Error(), jsonFieldPosition: queryErr.Pos}, true
+}
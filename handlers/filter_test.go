@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/query"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompileTaskQueryLengthLimit(t *testing.T) {
+	ctx := newQueryContext(primitive.NewObjectID(), nil, time.UTC)
+
+	if _, err := compileTaskQuery(ctx, strings.Repeat("a", maxTaskQueryLength)); err != nil {
+		t.Errorf("compileTaskQuery at the length limit failed: %v", err)
+	}
+
+	for _, input := range []string{strings.Repeat("a", maxTaskQueryLength+1), strings.Repeat("(", 10_000_000)} {
+		_, err := compileTaskQuery(ctx, input)
+		var queryErr *query.Error
+		if !errors.As(err, &queryErr) || queryErr.Pos != maxTaskQueryLength {
+			t.Errorf("compileTaskQuery(%d bytes) = %v, want a length error at %d", len(input), err, maxTaskQueryLength)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoIndexOptionsConflict is the server error code for creating an index that already
+// exists with other options
+const mongoIndexOptionsConflict = 85
+
+// ensureTTLIndex creates a TTL index, or changes the expiry of the existing one when the
+// retention was configured differently before
+func ensureTTLIndex(service *services.MongoService, keys bson.D, ttl time.Duration) error {
+	seconds := int32(ttl.Seconds())
+	err := service.EnsureIndex(keys, options.Index().SetExpireAfterSeconds(seconds))
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(mongoIndexOptionsConflict) {
+		return service.SetIndexExpiry(keys, seconds)
+	}
+	return err
+}
+
+// EnsureIndexes creates the indexes the handlers rely on. Creating an index that
+// already exists is a no-op, so this is safe to run on every start.
+func EnsureIndexes() error {
+	// Revoked tokens are only needed until the token would have expired anyway
+	if err := services.RevokedTokenService.EnsureIndex(
+		bson.D{{Key: "expiresAt", Value: 1}},
+		options.Index().SetExpireAfterSeconds(0),
+	); err != nil {
+		return err
+	}
+
+	if err := services.RevokedTokenService.EnsureIndex(bson.D{{Key: "tokenId", Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Event replay reads a board's log in sequence order
+	if err := EventService.EnsureIndex(
+		bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldSeq, Value: 1}},
+		options.Index().SetUnique(true),
+	); err != nil {
+		return err
+	}
+
+	// Task listings read a column at a time in rank order, or sort a board's tasks by a field
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldStatus, Value: 1}, {Key: fieldRank, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldCreatedAt, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldName, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Search ranks tasks by the words in their name and description, favoring the name
+	if err := TaskService.EnsureIndex(
+		bson.D{{Key: fieldName, Value: "text"}, {Key: fieldDescription, Value: "text"}},
+		options.Index().
+			SetName(taskTextIndex).
+			SetWeights(bson.M{fieldName: searchNameWeight, fieldDescription: searchDescriptionWeight}),
+	); err != nil {
+		return err
+	}
+
+	// Saved filters are listed per board and user, and their names are unique there
+	if err := FilterService.EnsureIndex(
+		bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldUserID, Value: 1}, {Key: fieldName, Value: 1}},
+		options.Index().SetUnique(true),
+	); err != nil {
+		return err
+	}
+
+	// Overdue and due-soon filters scan tasks by due date
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldDueDate, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Claiming a reminder fails on the second attempt, which keeps it from being sent twice
+	if err := ReminderService.EnsureIndex(
+		bson.D{{Key: fieldTaskID, Value: 1}, {Key: fieldUserID, Value: 1}, {Key: fieldDueDate, Value: 1}, {Key: fieldLeadMinutes, Value: 1}},
+		options.Index().SetUnique(true),
+	); err != nil {
+		return err
+	}
+	if err := ensureTTLIndex(ReminderService, bson.D{{Key: fieldSentAt, Value: 1}}, reminderRetention); err != nil {
+		return err
+	}
+	if err := ReminderService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := ReminderPreferenceService.EnsureIndex(bson.D{{Key: fieldUserID, Value: 1}}, options.Index().SetUnique(true)); err != nil {
+		return err
+	}
+
+	// Label filters look up tasks by label
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldLabels, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Assigned-to-me lists look up tasks by assignee across boards
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldAssignees, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Comment threads are read per task in the order they were written
+	if err := CommentService.EnsureIndex(bson.D{{Key: fieldTaskID, Value: 1}, {Key: fieldCreatedAt, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := CommentService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Attachments are listed per task and deleted per task or board
+	if err := AttachmentService.EnsureIndex(bson.D{{Key: fieldTaskID, Value: 1}, {Key: fieldCreatedAt, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := AttachmentService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Histories and activity feeds are read newest first
+	if err := ActivityService.EnsureIndex(bson.D{{Key: fieldTaskID, Value: 1}, {Key: "_id", Value: -1}}, nil); err != nil {
+		return err
+	}
+	if err := ActivityService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: "_id", Value: -1}}, nil); err != nil {
+		return err
+	}
+
+	// Undo looks up a user's latest change
+	if err := ActivityService.EnsureIndex(bson.D{{Key: fieldUserID, Value: 1}, {Key: "_id", Value: -1}}, nil); err != nil {
+		return err
+	}
+
+	// The trash is listed per board and purged by age
+	if err := TrashService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldDeletedAt, Value: -1}}, nil); err != nil {
+		return err
+	}
+	if err := TrashService.EnsureIndex(bson.D{{Key: fieldDeletedAt, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Inboxes are paged newest first and counted by read state; cascades go by board and task
+	if err := NotificationService.EnsureIndex(bson.D{{Key: fieldUserID, Value: 1}, {Key: "_id", Value: -1}}, nil); err != nil {
+		return err
+	}
+	if err := NotificationService.EnsureIndex(bson.D{{Key: fieldUserID, Value: 1}, {Key: fieldRead, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := NotificationService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := NotificationService.EnsureIndex(bson.D{{Key: fieldTaskID, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Delta sync looks up recent changes and deletions per board
+	if err := TaskService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldUpdatedAt, Value: 1}}, nil); err != nil {
+		return err
+	}
+	if err := TombstoneService.EnsureIndex(bson.D{{Key: fieldBoardID, Value: 1}, {Key: fieldDeletedAt, Value: 1}}, nil); err != nil {
+		return err
+	}
+
+	// Tombstones are dropped once clients older than the retention must do a full sync
+	// anyway. The retention is configurable, so it may differ from the index's.
+	return ensureTTLIndex(TombstoneService, bson.D{{Key: fieldDeletedAt, Value: 1}}, TombstoneRetention)
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// validateLabel checks a label definition against the other labels of the board.
+// Label names are unique per board, ignoring case.
+func validateLabel(board *models.Board, label models.Label) string {
+	if label.Name == "" {
+		return errLabelNameRequired
+	}
+
+	if label.Color != "" && !columnColorPattern.MatchString(label.Color) {
+		return errInvalidLabelColor
+	}
+
+	for _, existing := range board.Labels {
+		if existing.ID != label.ID && strings.EqualFold(existing.Name, label.Name) {
+			return errLabelNameTaken
+		}
+	}
+	return ""
+}
+
+// findLabel returns the index of the label in the board's catalogue, or -1
+func findLabel(board *models.Board, labelID primitive.ObjectID) int {
+	for i, label := range board.Labels {
+		if label.ID == labelID {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveTaskLabels checks that every label of a task is in the board's catalogue and
+// drops duplicates, keeping the order the client gave
+func resolveTaskLabels(board *models.Board, labelIDs []primitive.ObjectID) ([]primitive.ObjectID, bool) {
+	resolved := make([]primitive.ObjectID, 0, len(labelIDs))
+	seen := make(map[primitive.ObjectID]bool, len(labelIDs))
+	for _, labelID := range labelIDs {
+		if findLabel(board, labelID) < 0 {
+			return nil, false
+		}
+		if !seen[labelID] {
+			seen[labelID] = true
+			resolved = append(resolved, labelID)
+		}
+	}
+	return resolved, true
+}
+
+// otherLabelNamed matches boards that have a label other than exceptID with the given
+// name, ignoring case
+func otherLabelNamed(name string, exceptID primitive.ObjectID) bson.M {
+	pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}
+	return bson.M{fieldLabels: bson.M{"$elemMatch": bson.M{"_id": bson.M{"$ne": exceptID}, fieldName: pattern}}}
+}
+
+// labelConflict explains why a label edit no longer applied: the label is gone or
+// another label took the name meanwhile
+func labelConflict(board *models.Board, labelID primitive.ObjectID) error {
+	var current models.Board
+	if err := BoardService.FindByID(board.ID, &current); err != nil {
+		return err
+	}
+	if !labelID.IsZero() && findLabel(&current, labelID) < 0 {
+		return fiber.NewError(fiber.StatusNotFound, errLabelNotFound)
+	}
+	return fiber.NewError(fiber.StatusBadRequest, errLabelNameTaken)
+}
+
+// broadcastLabels tells the board's clients about a change to its label catalogue
+func broadcastLabels(action string, board *models.Board, userID, labelID primitive.ObjectID) {
+	labels := board.Labels
+	if labels == nil {
+		labels = []models.Label{}
+	}
+	BroadcastBoardChange(messageTypeLabels, board, userID, LabelEvent{Action: action, LabelID: labelID.Hex(), Labels: labels})
+}
+
+// labelIDs resolves a label given by ID or name against the boards. Names are matched
+// ignoring case, so the same name may stand for a label on each of several boards.
+func labelIDs(boards []models.Board, label string) []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	labelID, idErr := primitive.ObjectIDFromHex(label)
+	for i := range boards {
+		for _, existing := range boards[i].Labels {
+			if (idErr == nil && existing.ID == labelID) || strings.EqualFold(existing.Name, label) {
+				ids = append(ids, existing.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// labelQueryFilter keeps tasks that have any of the listed labels, given by ID or name
+func labelQueryFilter(c *fiber.Ctx, boards []models.Board) (bson.M, error) {
+	labels := splitQueryList(c.Query(queryLabel))
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	var ids []primitive.ObjectID
+	for _, label := range labels {
+		matched := labelIDs(boards, label)
+		if len(matched) == 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errLabelNotFound+": "+label)
+		}
+		ids = append(ids, matched...)
+	}
+	return bson.M{fieldLabels: bson.M{"$in": ids}}, nil
+}
+
+func GetLabels(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	labels := board.Labels
+	if labels == nil {
+		labels = []models.Label{}
+	}
+	return c.JSON(labels)
+}
+
+func CreateLabel(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req LabelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	label := models.Label{
+		ID:    primitive.NewObjectID(),
+		Name:  strings.TrimSpace(req.Name),
+		Color: req.Color,
+	}
+	if msg := validateLabel(board, label); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// Labels are pushed one at a time so that concurrent edits to other labels are kept
+	filter := bson.M{"$nor": []bson.M{otherLabelNamed(label.Name, label.ID)}}
+	ok, err := updateBoardIf(board, filter, bson.M{"$push": bson.M{fieldLabels: label}}, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return sendError(c, labelConflict(board, primitive.NilObjectID))
+	}
+
+	broadcastLabels(labelActionCreate, board, userObjectID, label.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(label)
+}
+
+func UpdateLabel(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	labelID, err := primitive.ObjectIDFromHex(c.Params(paramLabelID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req LabelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if findLabel(board, labelID) < 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errLabelNotFound})
+	}
+
+	label := models.Label{ID: labelID, Name: strings.TrimSpace(req.Name), Color: req.Color}
+	if msg := validateLabel(board, label); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// Only this label is rewritten, and only while no other label has taken its name
+	filter := bson.M{
+		fieldLabels + "._id": labelID,
+		"$nor":               []bson.M{otherLabelNamed(label.Name, labelID)},
+	}
+	update := bson.M{"$set": bson.M{
+		fieldLabels + ".$[label].name":  label.Name,
+		fieldLabels + ".$[label].color": label.Color,
+	}}
+	opts := options.FindOneAndUpdate().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"label._id": labelID}},
+	})
+	ok, err := updateBoardIf(board, filter, update, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return sendError(c, labelConflict(board, labelID))
+	}
+
+	broadcastLabels(labelActionUpdate, board, userObjectID, labelID)
+
+	return c.JSON(label)
+}
+
+func DeleteLabel(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	labelID, err := primitive.ObjectIDFromHex(c.Params(paramLabelID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	pull := bson.M{"$pull": bson.M{fieldLabels: bson.M{"_id": labelID}}}
+	ok, err := updateBoardIf(board, bson.M{fieldLabels + "._id": labelID}, pull, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errLabelNotFound})
+	}
+
+	// Take the label off every task that had it
+	filter := bson.M{fieldBoardID: id, fieldLabels: labelID}
+	update := bson.M{
+		"$pull": bson.M{fieldLabels: labelID},
+		"$set":  bson.M{fieldUpdatedAt: time.Now().UTC()},
+		"$inc":  bson.M{fieldVersion: 1},
+	}
+	if err := TaskService.UpdateManyRaw(filter, update); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	broadcastLabels(labelActionDelete, board, userObjectID, labelID)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
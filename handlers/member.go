@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// boardMembers returns the board's members, including the owner of boards created
+// before membership was introduced
+func boardMembers(board *models.Board) []models.BoardMember {
+	for _, member := range board.Members {
+		if member.UserID == board.OwnerID {
+			return board.Members
+		}
+	}
+	owner := models.BoardMember{UserID: board.OwnerID, Role: models.RoleOwner}
+	return append([]models.BoardMember{owner}, board.Members...)
+}
+
+// assignableRole reports whether a role can be granted through the member endpoints.
+// Every board has exactly one owner, so ownership cannot be granted this way.
+func assignableRole(role string) bool {
+	return role == models.RoleEditor || role == models.RoleViewer
+}
+
+// broadcastMembers tells the board's clients about a change to its members
+func broadcastMembers(action string, board *models.Board, userID, memberID primitive.ObjectID) {
+	BroadcastBoardChange(messageTypeMembers, board, userID, MemberEvent{
+		Action:  action,
+		UserID:  memberID.Hex(),
+		Members: boardMembers(board),
+	})
+}
+
+func GetMembers(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	return c.JSON(boardMembers(board))
+}
+
+func AddMember(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleOwner)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req MemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if !assignableRole(req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidRole})
+	}
+
+	// Invitees are looked up by ID or, failing that, by email
+	var user models.User
+	switch {
+	case !req.UserID.IsZero():
+		err = UserService.FindByID(req.UserID, &user)
+	case req.Email != "":
+		err = UserService.FindOne(bson.M{fieldEmail: req.Email}, &user)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errMemberRequired})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errUserNotFound})
+	}
+
+	if board.RoleOf(user.ID) != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errAlreadyMember})
+	}
+
+	// The member is pushed only while nobody else added them meanwhile
+	member := models.BoardMember{UserID: user.ID, Role: req.Role}
+	filter := bson.M{fieldOwnerID: bson.M{"$ne": user.ID}, fieldMembersUserID: bson.M{"$ne": user.ID}}
+	ok, err := updateBoardIf(board, filter, bson.M{"$push": bson.M{fieldMembers: member}}, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errAlreadyMember})
+	}
+
+	broadcastMembers(memberActionAdd, board, userObjectID, member.UserID)
+
+	return c.Status(fiber.StatusCreated).JSON(member)
+}
+
+func UpdateMember(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Params(paramUserID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleOwner)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req MemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if !assignableRole(req.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidRole})
+	}
+
+	if memberID == board.OwnerID {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errOwnerRoleFixed})
+	}
+
+	// Only this member's role is rewritten, and only while they are still a member
+	update := bson.M{"$set": bson.M{fieldMembers + ".$[member].role": req.Role}}
+	opts := options.FindOneAndUpdate().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"member.userId": memberID}},
+	})
+	ok, err := updateBoardIf(board, bson.M{fieldMembersUserID: memberID}, update, opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errMemberNotFound})
+	}
+
+	broadcastMembers(memberActionUpdate, board, userObjectID, memberID)
+
+	return c.JSON(models.BoardMember{UserID: memberID, Role: req.Role})
+}
+
+func RemoveMember(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(c.Params(paramUserID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+	}
+
+	// Members may leave a board on their own; removing someone else requires ownership
+	role := models.RoleOwner
+	if memberID == userObjectID {
+		role = models.RoleViewer
+	}
+
+	board, err := authorizeBoard(id, userObjectID, role)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if memberID == board.OwnerID {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errOwnerRoleFixed})
+	}
+
+	pull := bson.M{"$pull": bson.M{fieldMembers: bson.M{fieldUserID: memberID}}}
+	ok, err := updateBoardIf(board, bson.M{fieldMembersUserID: memberID}, pull, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errMemberNotFound})
+	}
+
+	// Saved filters are private to their user and go with the membership
+	if err := FilterService.DeleteMany(bson.M{fieldBoardID: id, fieldUserID: memberID}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// Notifications about a board the user has left would point at tasks they cannot open
+	if err := NotificationService.DeleteMany(bson.M{fieldBoardID: id, fieldUserID: memberID}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// Removed members no longer work on the board's tasks
+	if err := unassignMember(id, memberID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// The remaining members hear about it; the removed member's connections stop here
+	broadcastMembers(memberActionRemove, board, userObjectID, memberID)
+	RevokeBoardAccess(id, memberID)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mentionExcerptLength is the length of the text quoted in mention notifications
+const mentionExcerptLength = 200
+
+// isMentionRune reports whether the rune may continue a mention, so that "@ann" does not
+// match inside "@anna"
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// mentionHandles returns the ways a user can be mentioned: by name, as in "@Jane Doe",
+// and by the local part of their email address, as in "@jane.doe"
+func mentionHandles(user *models.User) []string {
+	var handles []string
+	if name := strings.TrimSpace(user.Name); name != "" {
+		handles = append(handles, name)
+	}
+	if at := strings.IndexByte(user.Email, '@'); at > 0 {
+		handles = append(handles, user.Email[:at])
+	}
+	return handles
+}
+
+// parseMentions finds the users mentioned in the text. Handles are matched ignoring case,
+// the longest one winning, and an @ inside a word, as in an email address, is not a mention.
+func parseMentions(text string, users []models.User) []primitive.ObjectID {
+	var mentioned []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' {
+			continue
+		}
+		if prev, _ := utf8.DecodeLastRuneInString(text[:i]); i > 0 && isMentionRune(prev) {
+			continue
+		}
+
+		rest := text[i+1:]
+		var best *models.User
+		bestLength := 0
+		for u := range users {
+			for _, handle := range mentionHandles(&users[u]) {
+				if len(handle) <= bestLength || len(handle) > len(rest) || !strings.EqualFold(rest[:len(handle)], handle) {
+					continue
+				}
+				if next, _ := utf8.DecodeRuneInString(rest[len(handle):]); isMentionRune(next) {
+					continue
+				}
+				best, bestLength = &users[u], len(handle)
+			}
+		}
+
+		if best != nil && !seen[best.ID] {
+			seen[best.ID] = true
+			mentioned = append(mentioned, best.ID)
+		}
+	}
+	return mentioned
+}
+
+// boardUsers loads the users who are members of the board
+func boardUsers(board *models.Board) ([]models.User, error) {
+	members := boardMembers(board)
+	ids := make([]primitive.ObjectID, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+
+	users := []models.User{}
+	err := UserService.Find(bson.M{"_id": bson.M{"$in": ids}}, &users)
+	return users, err
+}
+
+// mentionExcerpt shortens the text a user was mentioned in for their inbox
+func mentionExcerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= mentionExcerptLength {
+		return text
+	}
+	return string([]rune(text)[:mentionExcerptLength]) + "…"
+}
+
+// notifyMentions notifies the board members newly mentioned in a task description or
+// comment, that is mentioned in text but not in previous. Nobody is notified of their
+// own mentions. Failures are logged rather than failing the change that caused them.
+func notifyMentions(board *models.Board, task *models.Task, actorID primitive.ObjectID, previous, text string, commentID *primitive.ObjectID) {
+	if !strings.Contains(text, "@") {
+		return
+	}
+
+	users, err := boardUsers(board)
+	if err != nil {
+		log.Printf("Error loading members of board %s: %v", board.ID.Hex(), err)
+		return
+	}
+
+	already := make(map[primitive.ObjectID]bool)
+	for _, userID := range parseMentions(previous, users) {
+		already[userID] = true
+	}
+
+	now := time.Now().UTC()
+	for _, userID := range parseMentions(text, users) {
+		if already[userID] || userID == actorID {
+			continue
+		}
+		sendNotification(models.Notification{
+			UserID:    userID,
+			Type:      models.NotificationMention,
+			ActorID:   actorID,
+			BoardID:   board.ID,
+			TaskID:    task.ID,
+			CommentID: commentID,
+			TaskName:  task.Name,
+			Excerpt:   mentionExcerpt(text),
+			CreatedAt: now,
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultBoardName names the board that tasks from before boards existed are moved to
+	defaultBoardName = "My Tasks"
+
+	// migratedColumnColor colors the columns added for statuses the default columns lack
+	migratedColumnColor = "#9E9E9E"
+)
+
+// orphanedTasksFilter matches tasks created before tasks belonged to boards
+func orphanedTasksFilter() bson.M {
+	return bson.M{fieldBoardID: bson.M{"$in": bson.A{nil, primitive.NilObjectID}}}
+}
+
+// MigrateOrphanedTasks moves tasks without a board onto a "My Tasks" board of their
+// owner, which is created for the purpose. Tasks keep their status; statuses that match
+// none of the board's columns get a column of their own. Tasks already on a board are
+// left alone, so this is safe to run on every start.
+func MigrateOrphanedTasks() error {
+	tasks := []models.Task{}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if err := TaskService.FindWithOptions(orphanedTasksFilter(), opts, &tasks); err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	// Group the tasks by owner, oldest first
+	var owners []primitive.ObjectID
+	byOwner := make(map[primitive.ObjectID][]models.Task)
+	for _, task := range tasks {
+		if task.UserID.IsZero() {
+			log.Printf("Warning: Task %s has no owner and no board, leaving it as it is", task.ID.Hex())
+			continue
+		}
+		if _, ok := byOwner[task.UserID]; !ok {
+			owners = append(owners, task.UserID)
+		}
+		byOwner[task.UserID] = append(byOwner[task.UserID], task)
+	}
+
+	for _, owner := range owners {
+		board, err := defaultBoard(owner, byOwner[owner])
+		if err != nil {
+			return err
+		}
+		if err := moveOrphanedTasks(board, byOwner[owner]); err != nil {
+			return err
+		}
+		log.Printf("Moved %d tasks of user %s to board %s", len(byOwner[owner]), owner.Hex(), board.ID.Hex())
+	}
+	return nil
+}
+
+// defaultBoard returns the owner's migration board with a column for each of the tasks'
+// statuses, creating the board if an earlier run has not
+func defaultBoard(owner primitive.ObjectID, tasks []models.Task) (*models.Board, error) {
+	var boards []models.Board
+	filter := bson.M{fieldOwnerID: owner, fieldName: defaultBoardName}
+	if err := BoardService.FindWithOptions(filter, options.Find().SetLimit(1), &boards); err != nil {
+		return nil, err
+	}
+
+	var board models.Board
+	exists := len(boards) > 0
+	if exists {
+		board = boards[0]
+	} else {
+		board = models.Board{
+			Name:    defaultBoardName,
+			OwnerID: owner,
+			Columns: defaultColumns(),
+			Labels:  []models.Label{},
+			Members: []models.BoardMember{{UserID: owner, Role: models.RoleOwner}},
+		}
+	}
+
+	added := false
+	for _, task := range tasks {
+		name := strings.TrimSpace(task.Status)
+		if _, ok := resolveStatus(&board, name); ok {
+			continue
+		}
+		column := models.Column{ID: primitive.NewObjectID(), Name: name, Order: len(board.Columns), Color: migratedColumnColor}
+		if msg := validateColumn(&board, column); msg != "" {
+			// The task goes to the first column instead
+			continue
+		}
+		board.Columns = append(board.Columns, column)
+		added = true
+	}
+
+	if !exists {
+		id, err := BoardService.InsertOne(board)
+		if err != nil {
+			return nil, err
+		}
+		board.ID = id
+	} else if added {
+		if err := BoardService.UpdateByID(board.ID, bson.M{fieldColumns: board.Columns}); err != nil {
+			return nil, err
+		}
+	}
+	return &board, nil
+}
+
+// moveOrphanedTasks puts the tasks at the end of their columns on the board
+func moveOrphanedTasks(board *models.Board, tasks []models.Task) error {
+	now := time.Now().UTC()
+	last := make(map[string]string)
+	for _, task := range tasks {
+		status, ok := resolveStatus(board, task.Status)
+		if !ok {
+			status, _ = resolveStatus(board, "")
+		}
+
+		prev, seen := last[status]
+		if !seen {
+			var err error
+			if prev, err = lastRank(board.ID, status, primitive.NilObjectID); err != nil {
+				return err
+			}
+		}
+		rank := rankBetween(prev, "")
+		last[status] = rank
+
+		set := bson.M{
+			fieldBoardID:   board.ID,
+			fieldStatus:    status,
+			fieldRank:      rank,
+			fieldUpdatedAt: now,
+		}
+		if task.CreatedAt.IsZero() {
+			set[fieldCreatedAt] = task.ID.Timestamp().UTC()
+		}
+
+		// Matching on the missing board again keeps a concurrent start from moving the task twice
+		filter := orphanedTasksFilter()
+		filter["_id"] = task.ID
+		update := bson.M{"$set": set, "$inc": bson.M{fieldVersion: 1}}
+		if err := TaskService.UpdateManyRaw(filter, update); err != nil {
+			return err
+		}
+	}
+
+	// Many tasks in a row leave long ranks at the bottom of their columns
+	for status, rank := range last {
+		if len(rank) <= maxRankLength {
+			continue
+		}
+		if _, err := spreadRanks(board.ID, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
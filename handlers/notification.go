@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultFeedPageSize and maxFeedPageSize bound the size of a page of a newest-first
+	// listing such as the inbox or an activity feed
+	defaultFeedPageSize = 50
+	maxFeedPageSize     = 200
+)
+
+// sendNotification stores a notification in the recipient's inbox and pushes it to their open
+// connections. Failures are logged rather than failing the change that caused them.
+func sendNotification(notification models.Notification) {
+	id, err := NotificationService.InsertOne(notification)
+	if err != nil {
+		log.Printf("Error storing notification for user %s: %v", notification.UserID.Hex(), err)
+		return
+	}
+	notification.ID = id
+
+	SendToUser(notification.UserID, Message{
+		Type:    messageTypeNotification,
+		TaskID:  notification.TaskID.Hex(),
+		BoardID: notification.BoardID.Hex(),
+		UserID:  notification.UserID.Hex(),
+		Data:    notification,
+	})
+}
+
+// feedPage reads the limit and before query parameters of a newest-first listing. The
+// cursor is added to the filter; the options sort and limit the page.
+func feedPage(c *fiber.Ctx, filter bson.M) (*options.FindOptions, error) {
+	limit := defaultFeedPageSize
+	if value := c.Query(queryLimit); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxFeedPageSize {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidFeedLimit)
+		}
+	}
+
+	if value := c.Query(queryBefore); value != "" {
+		before, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+		}
+		filter["_id"] = bson.M{"$lt": before}
+	}
+
+	return options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit)), nil
+}
+
+// findNotification loads a notification of the caller's inbox named by the id route parameter
+func findNotification(c *fiber.Ctx, userID primitive.ObjectID) (*models.Notification, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+	}
+
+	var notification models.Notification
+	if err := NotificationService.FindOne(bson.M{"_id": id, fieldUserID: userID}, &notification); err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, errNotificationNotFound)
+	}
+	return &notification, nil
+}
+
+// GetNotifications lists the caller's inbox, newest first. Pass the ID of the last
+// notification seen as before to fetch the following page.
+func GetNotifications(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	filter := bson.M{fieldUserID: userObjectID}
+	if value := c.Query(queryUnread); value != "" {
+		unread, err := strconv.ParseBool(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidFlag + ": " + queryUnread})
+		}
+		filter[fieldRead] = !unread
+	}
+	opts, err := feedPage(c, filter)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	page := NotificationPage{Notifications: []models.Notification{}}
+	if err := NotificationService.FindWithOptions(filter, opts, &page.Notifications); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if page.Unread, err = NotificationService.Count(bson.M{fieldUserID: userObjectID, fieldRead: false}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(page)
+}
+
+func UpdateNotification(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	notification, err := findNotification(c, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req NotificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if req.Read == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errReadRequired})
+	}
+
+	if err := NotificationService.UpdateByID(notification.ID, bson.M{fieldRead: *req.Read}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	notification.Read = *req.Read
+	return c.JSON(notification)
+}
+
+// ReadAllNotifications marks the caller's whole inbox as read
+func ReadAllNotifications(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := NotificationService.UpdateMany(bson.M{fieldUserID: userObjectID, fieldRead: false}, bson.M{fieldRead: true}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func DeleteNotification(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	notification, err := findNotification(c, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := NotificationService.DeleteByID(notification.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
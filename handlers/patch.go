@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// taskField describes a task field that clients may change with PATCH. apply validates
+// the new JSON value and stores it on the task, returning an error message when the
+// value is not acceptable.
+type taskField struct {
+	bsonName string
+	apply    func(board *models.Board, task *models.Task, value json.RawMessage) string
+}
+
+// patchableTaskFields lists the task fields PATCH may change, keyed by JSON name.
+// Fields that are not listed, such as ids, rank and timestamps, are managed by the server.
+var patchableTaskFields = map[string]taskField{
+	fieldName: {
+		bsonName: fieldName,
+		apply: func(_ *models.Board, task *models.Task, value json.RawMessage) string {
+			var name string
+			if json.Unmarshal(value, &name) != nil {
+				return errPatchExpectedString
+			}
+			if strings.TrimSpace(name) == "" {
+				return errTaskNameRequired
+			}
+			task.Name = name
+			return ""
+		},
+	},
+	fieldDescription: {
+		bsonName: fieldDescription,
+		apply: func(_ *models.Board, task *models.Task, value json.RawMessage) string {
+			// Removing the description clears it
+			var description *string
+			if json.Unmarshal(value, &description) != nil {
+				return errPatchExpectedString
+			}
+			task.Description = ""
+			if description != nil {
+				task.Description = *description
+			}
+			return ""
+		},
+	},
+	fieldStatus: {
+		bsonName: fieldStatus,
+		apply: func(board *models.Board, task *models.Task, value json.RawMessage) string {
+			var status string
+			if json.Unmarshal(value, &status) != nil {
+				return errPatchExpectedString
+			}
+			resolved, ok := resolveStatus(board, status)
+			if !ok {
+				return errInvalidStatus
+			}
+			task.Status = resolved
+			return ""
+		},
+	},
+	fieldStartDate: {
+		bsonName: fieldStartDate,
+		apply: func(_ *models.Board, task *models.Task, value json.RawMessage) string {
+			return applyDate(&task.StartDate, value)
+		},
+	},
+	fieldDueDate: {
+		bsonName: fieldDueDate,
+		apply: func(_ *models.Board, task *models.Task, value json.RawMessage) string {
+			return applyDate(&task.DueDate, value)
+		},
+	},
+	fieldLabels: {
+		bsonName: fieldLabels,
+		apply: func(board *models.Board, task *models.Task, value json.RawMessage) string {
+			// Removing the labels clears them
+			var labelIDs []primitive.ObjectID
+			if json.Unmarshal(value, &labelIDs) != nil {
+				return errPatchExpectedIDs
+			}
+			resolved, ok := resolveTaskLabels(board, labelIDs)
+			if !ok {
+				return errUnknownLabel
+			}
+			task.Labels = resolved
+			return ""
+		},
+	},
+	fieldAssignees: {
+		bsonName: fieldAssignees,
+		apply: func(board *models.Board, task *models.Task, value json.RawMessage) string {
+			// Removing the assignees unassigns everyone
+			var userIDs []primitive.ObjectID
+			if json.Unmarshal(value, &userIDs) != nil {
+				return errPatchExpectedIDs
+			}
+			resolved, ok := resolveAssignees(board, userIDs)
+			if !ok {
+				return errAssigneeNotMember
+			}
+			task.Assignees = resolved
+			return ""
+		},
+	},
+	fieldTimeZone: {
+		bsonName: fieldTimeZone,
+		apply: func(_ *models.Board, task *models.Task, value json.RawMessage) string {
+			// Removing the time zone falls back to UTC
+			var timeZone *string
+			if json.Unmarshal(value, &timeZone) != nil {
+				return errPatchExpectedString
+			}
+			task.TimeZone = ""
+			if timeZone != nil {
+				task.TimeZone = *timeZone
+			}
+			return ""
+		},
+	},
+}
+
+// applyDate stores an RFC 3339 timestamp, or clears the date when the value is null
+func applyDate(date **time.Time, value json.RawMessage) string {
+	var t *time.Time
+	if json.Unmarshal(value, &t) != nil {
+		return errPatchExpectedDate
+	}
+	*date = t
+	return ""
+}
+
+// JSONPatchOperation is a single operation of an RFC 6902 JSON Patch document
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonEqual reports whether two JSON documents hold the same value
+func jsonEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// taskDocument returns the task's JSON representation keyed by field
+func taskDocument(task *models.Task) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// patchPathField maps a JSON Pointer to the top-level task field it names. Only whole
+// fields can be addressed, since every patchable field is a single value.
+func patchPathField(pointer string) (string, bool) {
+	if !strings.HasPrefix(pointer, "/") || strings.Count(pointer, "/") != 1 {
+		return "", false
+	}
+	field := strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:])
+	return field, field != ""
+}
+
+// jsonPatchToMergePatch applies a JSON Patch to the task's JSON representation and
+// returns the fields it changed as a merge patch
+func jsonPatchToMergePatch(task *models.Task, operations []JSONPatchOperation) (map[string]json.RawMessage, *fiber.Error) {
+	original, err := taskDocument(task)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	doc := make(map[string]json.RawMessage, len(original))
+	for key, value := range original {
+		doc[key] = value
+	}
+
+	null := json.RawMessage("null")
+	for _, operation := range operations {
+		field, ok := patchPathField(operation.Path)
+		if !ok {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errPatchInvalidPath+": "+operation.Path)
+		}
+
+		switch operation.Op {
+		case "add", "replace":
+			if operation.Value == nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, errPatchValueRequired)
+			}
+			doc[field] = operation.Value
+
+		case "remove":
+			doc[field] = null
+
+		case "copy", "move":
+			from, ok := patchPathField(operation.From)
+			if !ok {
+				return nil, fiber.NewError(fiber.StatusBadRequest, errPatchInvalidPath+": "+operation.From)
+			}
+			value, ok := doc[from]
+			if !ok {
+				return nil, fiber.NewError(fiber.StatusBadRequest, errPatchInvalidPath+": "+operation.From)
+			}
+			doc[field] = value
+			if operation.Op == "move" && from != field {
+				doc[from] = null
+			}
+
+		case "test":
+			if !jsonEqual(doc[field], operation.Value) {
+				return nil, fiber.NewError(fiber.StatusConflict, errPatchTestFailed+": "+operation.Path)
+			}
+
+		default:
+			return nil, fiber.NewError(fiber.StatusBadRequest, errPatchInvalidOp+": "+operation.Op)
+		}
+	}
+
+	// Only fields whose value differs from the original end up in the patch
+	patch := make(map[string]json.RawMessage)
+	for key, value := range doc {
+		if current, ok := original[key]; !ok || !jsonEqual(current, value) {
+			patch[key] = value
+		}
+	}
+	return patch, nil
+}
+
+// applyTaskPatch applies a merge patch to a copy of the task. It returns the patched task
+// and the JSON names of the fields whose value changed, or the validation error of every
+// field that could not be applied.
+func applyTaskPatch(board *models.Board, task *models.Task, patch map[string]json.RawMessage) (*models.Task, []string, map[string]string) {
+	original, _ := taskDocument(task)
+	patched := *task
+
+	fieldErrors := make(map[string]string)
+	for key, value := range patch {
+		field, ok := patchableTaskFields[key]
+		if !ok {
+			fieldErrors[key] = errFieldNotPatchable
+			continue
+		}
+		if msg := field.apply(board, &patched, value); msg != "" {
+			fieldErrors[key] = msg
+		}
+	}
+	if len(fieldErrors) == 0 {
+		if field, msg := normalizeTaskDates(&patched); msg != "" {
+			fieldErrors[field] = msg
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, nil, fieldErrors
+	}
+
+	updated, _ := taskDocument(&patched)
+	var changed []string
+	for key := range patch {
+		if !jsonEqual(original[key], updated[key]) {
+			changed = append(changed, key)
+		}
+	}
+	return &patched, changed, nil
+}
+
+// taskFieldValues picks the given fields from the task's JSON representation
+func taskFieldValues(task *models.Task, fields []string) map[string]json.RawMessage {
+	doc, _ := taskDocument(task)
+	values := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		values[field] = doc[field]
+	}
+	return values
+}
+
+// patchUpdate builds the update writing just the changed fields of a patched task, in
+// their stored representation
+func patchUpdate(patched *models.Task, changed []string) (bson.M, error) {
+	stored, err := bson.Marshal(patched)
+	if err != nil {
+		return nil, err
+	}
+	var storedFields bson.M
+	if err := bson.Unmarshal(stored, &storedFields); err != nil {
+		return nil, err
+	}
+
+	update := bson.M{}
+	for _, key := range changed {
+		bsonName := patchableTaskFields[key].bsonName
+		update[bsonName] = storedFields[bsonName]
+	}
+	return update, nil
+}
+
+func PatchTask(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := checkIfMatch(c, task); err != nil {
+		return sendError(c, err)
+	}
+
+	// Merge patches are the default; JSON Patch documents must say so in their content type
+	var patch map[string]json.RawMessage
+	switch contentType := strings.ToLower(string(c.Request().Header.ContentType())); {
+	case strings.HasPrefix(contentType, mimeJSONPatch):
+		var operations []JSONPatchOperation
+		if err := json.Unmarshal(c.Body(), &operations); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidRequestBody})
+		}
+		var patchErr *fiber.Error
+		if patch, patchErr = jsonPatchToMergePatch(task, operations); patchErr != nil {
+			return sendError(c, patchErr)
+		}
+
+	case contentType == "", strings.HasPrefix(contentType, mimeMergePatch), strings.HasPrefix(contentType, fiber.MIMEApplicationJSON):
+		if err := json.Unmarshal(c.Body(), &patch); err != nil || patch == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidRequestBody})
+		}
+
+	default:
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{jsonFieldError: errUnsupportedPatchType})
+	}
+
+	patched, changed, fieldErrors := applyTaskPatch(board, task, patch)
+	if fieldErrors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidPatch, jsonFieldFields: fieldErrors})
+	}
+
+	// Nothing to write when every supplied field already had its value
+	if len(changed) == 0 {
+		setDueFlags(board, task)
+		setTaskETag(c, task)
+		return c.JSON(task)
+	}
+
+	update, err := patchUpdate(patched, changed)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// A task that changes column goes to the bottom of its new column
+	if patched.Status != task.Status {
+		last, err := lastRank(task.BoardID, patched.Status, id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+		update[fieldRank] = rankBetween(last, "")
+		changed = append(changed, fieldRank)
+	}
+
+	before := taskSnapshot(task)
+	previousDescription := task.Description
+	if err := updateTaskVersion(task, update); err != nil {
+		return sendError(c, err)
+	}
+
+	setDueFlags(board, task)
+
+	recordActivity(models.ActivityUpdate, task, userObjectID, before)
+
+	// Broadcast just the changed fields to websocket clients. The due flags follow the
+	// due date and the column.
+	changed = append(changed, fieldUpdatedAt)
+	_, dueChanged := update[fieldDueDate]
+	_, statusChanged := update[fieldStatus]
+	if dueChanged || statusChanged {
+		changed = append(changed, fieldOverdue, fieldDueSoon)
+	}
+	BroadcastTaskChange(messageTypePatch, board, task, userObjectID, taskFieldValues(task, changed))
+	rebalanceColumn(board, task, userObjectID)
+
+	if _, ok := update[fieldDescription]; ok {
+		notifyMentions(board, task, userObjectID, previousDescription, task.Description, nil)
+	}
+
+	setTaskETag(c, task)
+	return c.JSON(task)
+}
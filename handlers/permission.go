@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// roleRank orders board roles so that a higher role includes every lower one
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// hasRole reports whether the user holds at least the given role on the board
+func hasRole(board *models.Board, userID primitive.ObjectID, role string) bool {
+	held, ok := roleRank[board.RoleOf(userID)]
+	return ok && held >= roleRank[role]
+}
+
+// memberBoardsFilter matches every board the user is a member of
+func memberBoardsFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{"$or": []bson.M{
+		{fieldOwnerID: userID},
+		{fieldMembersUserID: userID},
+	}}
+}
+
+// currentUserID returns the authenticated user's ID from the request context
+func currentUserID(c *fiber.Ctx) (primitive.ObjectID, error) {
+	userID, _ := c.Locals(contextKeyUserID).(string)
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, fiber.NewError(fiber.StatusBadRequest, errInvalidUserID)
+	}
+	return userObjectID, nil
+}
+
+// authorizeBoard loads a board and checks that the user holds at least the given role on it
+func authorizeBoard(boardID, userID primitive.ObjectID, role string) (*models.Board, error) {
+	var board models.Board
+	if err := BoardService.FindByID(boardID, &board); err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, errBoardNotFound)
+	}
+
+	if !hasRole(&board, userID, role) {
+		return nil, fiber.NewError(fiber.StatusForbidden, errAccessDenied)
+	}
+
+	return &board, nil
+}
+
+// authorizeTask loads a task with its board and checks that the user holds at least the
+// given role on that board
+func authorizeTask(taskID, userID primitive.ObjectID, role string) (*models.Task, *models.Board, error) {
+	var task models.Task
+	if err := TaskService.FindByID(taskID, &task); err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, errTaskNotFound)
+	}
+
+	board, err := authorizeBoard(task.BoardID, userID, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &task, board, nil
+}
+
+// visibleBoards returns the board named by the boardId query parameter, or every board the
+// user is a member of when the parameter is absent
+func visibleBoards(c *fiber.Ctx, userID primitive.ObjectID) ([]models.Board, error) {
+	boardID := c.Query(queryBoardID)
+	if boardID == "" {
+		boards := []models.Board{}
+		if err := BoardService.Find(memberBoardsFilter(userID), &boards); err != nil {
+			return nil, err
+		}
+		return boards, nil
+	}
+
+	boardObjectID, err := primitive.ObjectIDFromHex(boardID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidBoardID)
+	}
+
+	board, err := authorizeBoard(boardObjectID, userID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Board{*board}, nil
+}
+
+// sendError writes err as a JSON error response, taking the status code from a *fiber.Error.
+// Task query errors are reported as bad requests along with their position.
+func sendError(c *fiber.Ctx, err error) error {
+	if body, ok := queryErrorResponse(err); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(body)
+	}
+
+	code := fiber.StatusInternalServerError
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code = fiberErr.Code
+	}
+	return c.Status(code).JSON(fiber.Map{jsonFieldError: err.Error()})
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rankAlphabet holds the digits of task ranks in ascending byte order, so ranks
+// compare correctly as plain strings (and therefore in Mongo sorts)
+const rankAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// maxRankLength is the longest rank a task is left with before the ranks of its column
+// are spread out again. Putting task after task in the same place, such as the bottom of
+// a column, makes ranks a digit longer every few tasks.
+const maxRankLength = 32
+
+// rankBetween returns a rank that sorts strictly between prev and next. An empty prev
+// means the start of the column and an empty next means its end. Generated ranks never
+// end in the lowest digit, which guarantees there is always room in front of any rank.
+func rankBetween(prev, next string) string {
+	base := len(rankAlphabet)
+	digit := func(s string, i int) int {
+		return strings.IndexByte(rankAlphabet, s[i])
+	}
+
+	var out []byte
+	// Once a digit below next's digit has been emitted, the remaining digits are unbounded
+	bounded := next != ""
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(prev) {
+			lo = digit(prev, i)
+		}
+		hi := base
+		if bounded && i < len(next) {
+			hi = digit(next, i)
+		}
+
+		if hi-lo > 1 {
+			return string(append(out, rankAlphabet[(lo+hi)/2]))
+		}
+
+		out = append(out, rankAlphabet[lo])
+		if hi > lo {
+			bounded = false
+		}
+	}
+}
+
+// neighbourRank returns the rank of the closest task in a column on one side of rank,
+// skipping the task being moved. An empty string means there is no such task.
+func neighbourRank(boardID primitive.ObjectID, status, rank string, after bool, skip primitive.ObjectID) (string, error) {
+	op, direction := "$lt", -1
+	if after {
+		op, direction = "$gt", 1
+	}
+
+	filter := bson.M{
+		fieldBoardID: boardID,
+		fieldStatus:  status,
+		fieldRank:    bson.M{op: rank},
+		"_id":        bson.M{"$ne": skip},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: fieldRank, Value: direction}}).SetLimit(1)
+
+	var tasks []models.Task
+	if err := TaskService.FindWithOptions(filter, opts, &tasks); err != nil {
+		return "", err
+	}
+	if len(tasks) == 0 {
+		return "", nil
+	}
+	return tasks[0].Rank, nil
+}
+
+// separateTiedRanks gives the tasks of a column that share rank fresh ranks of their own,
+// keeping the order they are listed in, which breaks ties by ID. Tasks created at the same
+// moment can end up with the same rank, and no rank sorts between those. The task being
+// moved is skipped. It reports whether any task was given a new rank.
+func separateTiedRanks(board *models.Board, status, rank string, skip, userID primitive.ObjectID) (bool, error) {
+	filter := bson.M{fieldBoardID: board.ID, fieldStatus: status, fieldRank: rank, "_id": bson.M{"$ne": skip}}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	var tied []models.Task
+	if err := TaskService.FindWithOptions(filter, opts, &tied); err != nil {
+		return false, err
+	}
+	if len(tied) < 2 {
+		return false, nil
+	}
+
+	next, err := neighbourRank(board.ID, status, rank, true, skip)
+	if err != nil {
+		return false, err
+	}
+
+	// The first task keeps its rank and the others follow it, in front of the next task
+	prev := rank
+	for i := 1; i < len(tied); i++ {
+		task := &tied[i]
+		prev = rankBetween(prev, next)
+		if err := updateTaskVersion(task, bson.M{fieldRank: prev}); err != nil {
+			return false, err
+		}
+		BroadcastTaskChange(messageTypePatch, board, task, userID, taskFieldValues(task, []string{fieldRank, fieldUpdatedAt}))
+	}
+	return true, nil
+}
+
+// lastRank returns the rank of the bottom task of a column, or an empty string when
+// the column is empty
+func lastRank(boardID primitive.ObjectID, status string, skip primitive.ObjectID) (string, error) {
+	filter := bson.M{fieldBoardID: boardID, fieldStatus: status, "_id": bson.M{"$ne": skip}}
+	opts := options.Find().SetSort(bson.D{{Key: fieldRank, Value: -1}}).SetLimit(1)
+
+	var tasks []models.Task
+	if err := TaskService.FindWithOptions(filter, opts, &tasks); err != nil {
+		return "", err
+	}
+	if len(tasks) == 0 {
+		return "", nil
+	}
+	return tasks[0].Rank, nil
+}
+
+// evenRanks returns n ascending ranks spread evenly over the range of ranks, using as few
+// digits as leave a gap of at least a whole digit between neighbours. Like the ranks
+// rankBetween generates, they never end in the lowest digit.
+func evenRanks(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	base := len(rankAlphabet)
+	width, span := 1, base
+	for span/(n+1) < base {
+		width++
+		span *= base
+	}
+	step := span / (n + 1)
+
+	ranks := make([]string, n)
+	digits := make([]byte, width)
+	for i := range ranks {
+		value := (i + 1) * step
+		for j := width - 1; j >= 0; j-- {
+			digits[j] = rankAlphabet[value%base]
+			value /= base
+		}
+		ranks[i] = strings.TrimRight(string(digits), rankAlphabet[:1])
+	}
+	return ranks
+}
+
+// spreadRanks gives the tasks of a column evenly spread ranks in their current order and
+// returns the tasks whose rank changed. Tasks that moved while this runs are left alone.
+func spreadRanks(boardID primitive.ObjectID, status string) ([]models.Task, error) {
+	filter := bson.M{fieldBoardID: boardID, fieldStatus: status}
+	opts := options.Find().SetSort(bson.D{{Key: fieldRank, Value: 1}, {Key: "_id", Value: 1}})
+
+	var tasks []models.Task
+	if err := TaskService.FindWithOptions(filter, opts, &tasks); err != nil {
+		return nil, err
+	}
+
+	var changed []models.Task
+	for i, rank := range evenRanks(len(tasks)) {
+		task := &tasks[i]
+		if task.Rank == rank {
+			continue
+		}
+		// Matching on the old place instead of the version lets edits to other fields through
+		filter := bson.M{"_id": task.ID, fieldStatus: status, fieldRank: task.Rank}
+		update := bson.M{
+			"$set": bson.M{fieldRank: rank, fieldUpdatedAt: time.Now().UTC()},
+			"$inc": bson.M{fieldVersion: 1},
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		var updated models.Task
+		err := TaskService.FindOneAndUpdate(filter, update, opts, &updated)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return changed, err
+		}
+		changed = append(changed, updated)
+	}
+	return changed, nil
+}
+
+// rebalanceColumn spreads the ranks of the task's column out again once the task has been
+// given a rank longer than maxRankLength. Every task that gets a new rank is broadcast as a
+// patch, and task itself is refreshed. The task has been stored already, so failures are
+// only logged.
+func rebalanceColumn(board *models.Board, task *models.Task, userID primitive.ObjectID) {
+	if len(task.Rank) <= maxRankLength {
+		return
+	}
+
+	changed, err := spreadRanks(board.ID, task.Status)
+	if err != nil {
+		log.Printf("Error spreading the ranks of column %q on board %s: %v", task.Status, board.ID.Hex(), err)
+	}
+	for i := range changed {
+		moved := &changed[i]
+		if moved.ID == task.ID {
+			setDueFlags(board, moved)
+			*task = *moved
+		}
+		BroadcastTaskChange(messageTypePatch, board, moved, userID, taskFieldValues(moved, []string{fieldRank, fieldUpdatedAt}))
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkBetween fails the test unless rank sorts strictly between prev and next and is a
+// rank rankBetween could have generated
+func checkBetween(t *testing.T, prev, next, rank string) {
+	t.Helper()
+	if rank == "" {
+		t.Fatalf("rankBetween(%q, %q) is empty", prev, next)
+	}
+	if prev != "" && rank <= prev {
+		t.Fatalf("rankBetween(%q, %q) = %q, not after %q", prev, next, rank, prev)
+	}
+	if next != "" && rank >= next {
+		t.Fatalf("rankBetween(%q, %q) = %q, not before %q", prev, next, rank, next)
+	}
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(rankAlphabet, rank[i]) < 0 {
+			t.Fatalf("rankBetween(%q, %q) = %q, which has digit %q outside the alphabet", prev, next, rank, rank[i])
+		}
+	}
+	if rank[len(rank)-1] == rankAlphabet[0] {
+		t.Fatalf("rankBetween(%q, %q) = %q, which ends in the lowest digit", prev, next, rank)
+	}
+}
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		prev, next string
+	}{
+		{"", ""},
+		{"i", ""},
+		{"", "i"},
+		{"z", ""},
+		{"zzz", ""},
+		{"", "1"},
+		{"", "01"},
+		{"a", "b"},
+		{"a", "c"},
+		{"ay", "b"},
+		{"azz", "b"},
+		{"i", "i1"},
+		{"i", "i5"},
+		{"i", "iz"},
+		{"i5", "j"},
+		{"i01", "i02"},
+		{"0001", "0002"},
+		{"y", "z"},
+		{"yz", "z"},
+	}
+	for _, tt := range tests {
+		checkBetween(t, tt.prev, tt.next, rankBetween(tt.prev, tt.next))
+	}
+}
+
+func TestRankBetweenEmptyColumn(t *testing.T) {
+	if got := rankBetween("", ""); got != "i" {
+		t.Errorf(`rankBetween("", "") = %q, want "i"`, got)
+	}
+}
+
+func TestRankBetweenRepeatedTailInserts(t *testing.T) {
+	last := ""
+	for i := 0; i < 1000; i++ {
+		rank := rankBetween(last, "")
+		checkBetween(t, last, "", rank)
+		last = rank
+	}
+}
+
+func TestRankBetweenRepeatedHeadInserts(t *testing.T) {
+	first := ""
+	for i := 0; i < 1000; i++ {
+		rank := rankBetween("", first)
+		checkBetween(t, "", first, rank)
+		first = rank
+	}
+}
+
+func TestRankBetweenRepeatedInsertsAfterSameTask(t *testing.T) {
+	// Dropping task after task right behind the same one keeps narrowing the same gap
+	prev, next := rankBetween("", ""), ""
+	next = rankBetween(prev, next)
+	for i := 0; i < 1000; i++ {
+		rank := rankBetween(prev, next)
+		checkBetween(t, prev, next, rank)
+		next = rank
+	}
+}
+
+func TestRankBetweenAlternatingInserts(t *testing.T) {
+	// Inserting in the middle of a gap, then in either half, never runs out of room
+	prev, next := "", ""
+	for i := 0; i < 1000; i++ {
+		rank := rankBetween(prev, next)
+		checkBetween(t, prev, next, rank)
+		if i%2 == 0 {
+			prev = rank
+		} else {
+			next = rank
+		}
+	}
+}
+
+func TestEvenRanks(t *testing.T) {
+	if got := evenRanks(0); len(got) != 0 {
+		t.Errorf("evenRanks(0) = %q, want none", got)
+	}
+	if got := evenRanks(1); len(got) != 1 || got[0] != rankBetween("", "") {
+		t.Errorf("evenRanks(1) = %q, want [%q]", got, rankBetween("", ""))
+	}
+
+	for _, n := range []int{2, 3, 35, 36, 100, 1295, 1296, 10000} {
+		ranks := evenRanks(n)
+		if len(ranks) != n {
+			t.Fatalf("evenRanks(%d) returns %d ranks", n, len(ranks))
+		}
+		for i, rank := range ranks {
+			prev, next := "", ""
+			if i > 0 {
+				prev = ranks[i-1]
+			}
+			if i < n-1 {
+				next = ranks[i+1]
+			}
+			checkBetween(t, prev, next, rank)
+			// Neighbours leave room for a rank at most a digit longer than either of them
+			if gap := rankBetween(prev, next); len(gap) > max(len(prev), len(next))+1 {
+				t.Errorf("evenRanks(%d) leaves no room between %q and %q", n, prev, next)
+			}
+		}
+		if last := ranks[n-1]; len(last) > 4 {
+			t.Errorf("evenRanks(%d) ends in %q", n, last)
+		}
+	}
+}
+
+func TestEvenRanksAfterRepeatedTailInserts(t *testing.T) {
+	// A column filled from the bottom goes over the limit, then starts again from short ranks
+	var ranks []string
+	last := ""
+	for len(last) <= maxRankLength {
+		last = rankBetween(last, "")
+		ranks = append(ranks, last)
+	}
+	if len(ranks) < 100 {
+		t.Errorf("ranks pass %d digits after %d tail inserts", maxRankLength, len(ranks))
+	}
+
+	spread := evenRanks(len(ranks))
+	for i := 0; i < 100; i++ {
+		spread = append(spread, rankBetween(spread[len(spread)-1], ""))
+	}
+	for i := 1; i < len(spread); i++ {
+		if spread[i] <= spread[i-1] {
+			t.Fatalf("rank %d (%q) is not after %q", i, spread[i], spread[i-1])
+		}
+	}
+	if last := spread[len(spread)-1]; len(last) > maxRankLength {
+		t.Errorf("100 tail inserts after spreading reach %q", last)
+	}
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/notify"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// maxReminderLead is the furthest ahead of a due date a reminder can be sent
+	maxReminderLead = 7 * 24 * time.Hour
+
+	// maxReminderLeads caps the number of reminders per task and user
+	maxReminderLeads = 5
+
+	// reminderRetention is how long sent reminders are remembered. It bounds the catch-up
+	// window, since a reminder must still be on record whenever it could fire again.
+	reminderRetention = 30 * 24 * time.Hour
+
+	// notifyTimeout bounds the delivery of a reminder to the configured notifier
+	notifyTimeout = 15 * time.Second
+)
+
+// defaultReminderLeads are the reminders of users who never set preferences: a day
+// before and an hour before the due date
+var defaultReminderLeads = []int{24 * 60, 60}
+
+// ReminderCatchUp is how late a reminder may still be sent, for example after the server
+// was down when it was due. Older reminders are skipped.
+var ReminderCatchUp = time.Hour
+
+// SetReminderCatchUp sets how late a reminder may still be sent
+func SetReminderCatchUp(catchUp time.Duration) {
+	if catchUp > reminderRetention {
+		log.Printf("Warning: Reminder catch-up capped at %s", reminderRetention)
+		catchUp = reminderRetention
+	}
+	ReminderCatchUp = catchUp
+}
+
+// reminderNotifier receives reminders in addition to the user's websocket clients
+var reminderNotifier notify.Notifier
+
+// SetReminderNotifier sets where reminders are delivered besides the websocket
+func SetReminderNotifier(notifier notify.Notifier) {
+	reminderNotifier = notifier
+}
+
+// defaultReminderPreferences returns the preferences of a user who never set any
+func defaultReminderPreferences(userID primitive.ObjectID) models.ReminderPreferences {
+	return models.ReminderPreferences{
+		UserID:      userID,
+		Enabled:     true,
+		LeadMinutes: append([]int(nil), defaultReminderLeads...),
+	}
+}
+
+// findReminderPreferences loads the preferences of the users, falling back to the
+// defaults for users without any
+func findReminderPreferences(userIDs []primitive.ObjectID) (map[primitive.ObjectID]models.ReminderPreferences, error) {
+	stored := []models.ReminderPreferences{}
+	if err := ReminderPreferenceService.Find(bson.M{fieldUserID: bson.M{"$in": userIDs}}, &stored); err != nil {
+		return nil, err
+	}
+
+	preferences := make(map[primitive.ObjectID]models.ReminderPreferences, len(userIDs))
+	for _, userID := range userIDs {
+		preferences[userID] = defaultReminderPreferences(userID)
+	}
+	for _, prefs := range stored {
+		preferences[prefs.UserID] = prefs
+	}
+	return preferences, nil
+}
+
+// validateLeadMinutes checks reminder lead times and returns them latest-first
+func validateLeadMinutes(leads []int) ([]int, error) {
+	if len(leads) > maxReminderLeads {
+		return nil, fiber.NewError(fiber.StatusBadRequest, errTooManyLeadTimes)
+	}
+
+	seen := make(map[int]bool, len(leads))
+	for _, lead := range leads {
+		if lead < 0 || time.Duration(lead)*time.Minute > maxReminderLead || seen[lead] {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidLeadMinutes)
+		}
+		seen[lead] = true
+	}
+
+	sorted := append([]int{}, leads...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	return sorted, nil
+}
+
+func GetReminderPreferences(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	preferences, err := findReminderPreferences([]primitive.ObjectID{userObjectID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(preferences[userObjectID])
+}
+
+func UpdateReminderPreferences(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	var req ReminderPreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	current, err := findReminderPreferences([]primitive.ObjectID{userObjectID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	prefs := current[userObjectID]
+
+	if req.Enabled != nil {
+		prefs.Enabled = *req.Enabled
+	}
+	if req.LeadMinutes != nil {
+		if prefs.LeadMinutes, err = validateLeadMinutes(req.LeadMinutes); err != nil {
+			return sendError(c, err)
+		}
+	}
+	prefs.UpdatedAt = time.Now().UTC()
+
+	// Preferences are created on first change
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{
+		fieldEnabled:     prefs.Enabled,
+		fieldLeadMinutes: prefs.LeadMinutes,
+		fieldUpdatedAt:   prefs.UpdatedAt,
+	}}
+	if err := ReminderPreferenceService.FindOneAndUpdate(bson.M{fieldUserID: userObjectID}, update, opts, &prefs); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	return c.JSON(prefs)
+}
+
+// reminderRecipients returns the users reminded of the task: its assignees and its
+// creator, as long as they are still members of the board
+func reminderRecipients(board *models.Board, task *models.Task) []primitive.ObjectID {
+	var recipients []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for _, userID := range append([]primitive.ObjectID{task.UserID}, task.Assignees...) {
+		if !seen[userID] && board.RoleOf(userID) != "" {
+			seen[userID] = true
+			recipients = append(recipients, userID)
+		}
+	}
+	return recipients
+}
+
+// claimReminder records the reminder as sent. It returns false when it was sent before,
+// by this or another server, so that every reminder goes out at most once.
+func claimReminder(reminder models.Reminder) (bool, error) {
+	_, err := ReminderService.InsertOne(reminder)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// deliverReminder sends the reminder to the user's websocket clients and the notifier
+func deliverReminder(board *models.Board, task *models.Task, reminder models.Reminder) {
+	data := ReminderData{TaskName: task.Name, DueDate: reminder.DueDate, LeadMinutes: reminder.LeadMinutes}
+	SendToUser(reminder.UserID, Message{
+		Type:    messageTypeReminder,
+		TaskID:  task.ID.Hex(),
+		Version: task.Version,
+		BoardID: board.ID.Hex(),
+		UserID:  reminder.UserID.Hex(),
+		Data:    data,
+	})
+
+	if reminderNotifier == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	err := reminderNotifier.Notify(ctx, notify.Notification{
+		Type:    messageTypeReminder,
+		UserID:  reminder.UserID.Hex(),
+		BoardID: board.ID.Hex(),
+		TaskID:  task.ID.Hex(),
+		Title:   task.Name,
+		Data:    data,
+	})
+	if err != nil {
+		log.Printf("Error notifying user %s of task %s: %v", reminder.UserID.Hex(), task.ID.Hex(), err)
+	}
+}
+
+// SendDueReminders sends the reminders that have fallen due by now. Reminders are
+// claimed in the database before they are sent, so running it again, after a restart
+// or on several servers at once, never sends a reminder twice. It is meant to be run
+// periodically by the scheduler.
+func SendDueReminders(now time.Time) error {
+	now = now.UTC()
+	earliest := now.Add(-ReminderCatchUp)
+
+	// A reminder fires lead minutes before the due date, so only tasks due between the
+	// oldest reminder still worth sending and the longest lead are candidates
+	tasks := []models.Task{}
+	filter := bson.M{
+		fieldDueDate:  bson.M{"$gte": earliest, "$lte": now.Add(maxReminderLead)},
+		fieldArchived: bson.M{"$ne": true},
+	}
+	if err := TaskService.Find(filter, &tasks); err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	boardIDs := make([]primitive.ObjectID, 0, len(tasks))
+	for _, task := range tasks {
+		boardIDs = append(boardIDs, task.BoardID)
+	}
+	boards := []models.Board{}
+	if err := BoardService.Find(bson.M{"_id": bson.M{"$in": boardIDs}}, &boards); err != nil {
+		return err
+	}
+	byID := make(map[primitive.ObjectID]*models.Board, len(boards))
+	for i := range boards {
+		byID[boards[i].ID] = &boards[i]
+	}
+
+	// Finished tasks need no reminding
+	recipients := make(map[primitive.ObjectID][]primitive.ObjectID, len(tasks))
+	var userIDs []primitive.ObjectID
+	for i := range tasks {
+		board, ok := byID[tasks[i].BoardID]
+		if !ok || tasks[i].Status == doneStatus(board) {
+			continue
+		}
+		recipients[tasks[i].ID] = reminderRecipients(board, &tasks[i])
+		userIDs = append(userIDs, recipients[tasks[i].ID]...)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	preferences, err := findReminderPreferences(userIDs)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range tasks {
+		task := &tasks[i]
+		for _, userID := range recipients[task.ID] {
+			prefs := preferences[userID]
+			if !prefs.Enabled {
+				continue
+			}
+			// When several reminders are due at once, as for a task created shortly before
+			// its due date, all are claimed but only the closest one is sent
+			var latest *models.Reminder
+			for _, lead := range prefs.LeadMinutes {
+				fireAt := task.DueDate.Add(-time.Duration(lead) * time.Minute)
+				if fireAt.After(now) || fireAt.Before(earliest) {
+					continue
+				}
+
+				reminder := models.Reminder{
+					TaskID:      task.ID,
+					BoardID:     task.BoardID,
+					UserID:      userID,
+					DueDate:     *task.DueDate,
+					LeadMinutes: lead,
+					SentAt:      now,
+				}
+				claimed, err := claimReminder(reminder)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if claimed {
+					latest = &reminder
+				}
+			}
+			if latest != nil {
+				deliverReminder(byID[task.BoardID], task, *latest)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
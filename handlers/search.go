@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"errors"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultSearchResults and maxSearchResults bound the number of results per search
+	defaultSearchResults = 20
+	maxSearchResults     = 100
+
+	// maxSearchCandidates caps the tasks scored in process when there is no text index
+	maxSearchCandidates = 1000
+
+	// Matches in the name count for more than matches in the description
+	searchNameWeight        = 3
+	searchDescriptionWeight = 1
+
+	// snippetLength is the length of description snippets; snippets start up to
+	// snippetContext bytes before the first match
+	snippetLength  = 160
+	snippetContext = 40
+
+	// taskTextIndex is the name of the text index over task names and descriptions
+	taskTextIndex = "task_text"
+
+	// mongoIndexNotFound is the server error code for a $text query without a text index
+	mongoIndexNotFound = 27
+)
+
+// SearchTextIndex selects whether searches are ranked by Mongo's text index or in process.
+// Even with the index selected, searches fall back to ranking in process when the
+// database turns out to have no text index.
+var SearchTextIndex = true
+
+// SetSearchTextIndex selects how searches are ranked
+func SetSearchTextIndex(enabled bool) {
+	SearchTextIndex = enabled
+}
+
+// searchQuery is a parsed search string. Like Mongo's $text search, a task matches when
+// it contains any of the terms and none of the excluded terms. Quoted phrases count as
+// a single term.
+type searchQuery struct {
+	terms    []string
+	excluded []string
+}
+
+// parseSearchQuery splits a search string into words, "quoted phrases" and -excluded terms
+func parseSearchQuery(q string) searchQuery {
+	var query searchQuery
+	add := func(term string, exclude bool) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return
+		}
+		if exclude {
+			query.excluded = append(query.excluded, term)
+		} else {
+			query.terms = append(query.terms, term)
+		}
+	}
+
+	for i := 0; i < len(q); {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		exclude := false
+		// As in $text search, a hyphen only excludes at the start of a term; inside a
+		// word like pre-market it separates words
+		prev, _ := utf8.DecodeLastRuneInString(q[:i])
+		if r == '-' && (i == 0 || unicode.IsSpace(prev)) {
+			exclude = true
+			i += size
+			if i >= len(q) {
+				break
+			}
+			r, size = utf8.DecodeRuneInString(q[i:])
+		}
+
+		switch {
+		case r == '"':
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				end = len(q) - i - 1
+			}
+			add(q[i+1:i+1+end], exclude)
+			i += end + 2
+
+		case isWordRune(r):
+			start := i
+			for i < len(q) {
+				r, size := utf8.DecodeRuneInString(q[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+			}
+			add(q[start:i], exclude)
+
+		default:
+			i += size
+		}
+	}
+	return query
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// textMatch is the byte range of a term found in a text
+type textMatch struct {
+	start, end int
+}
+
+// findMatches returns the non-overlapping places where a word of the text starts with one
+// of the terms, ignoring case
+func findMatches(text string, terms []string) []textMatch {
+	var matches []textMatch
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if isWordRune(r) && !isWordRune(prev) {
+			if end := matchTermAt(text, i, terms); end > i {
+				matches = append(matches, textMatch{start: i, end: end})
+				prev, _ = utf8.DecodeLastRuneInString(text[:end])
+				i = end
+				continue
+			}
+		}
+		prev = r
+		i += size
+	}
+	return matches
+}
+
+// matchTermAt returns the end of the first term found at position i of the text, or i
+func matchTermAt(text string, i int, terms []string) int {
+	for _, term := range terms {
+		end := i + len(term)
+		if end <= len(text) && strings.EqualFold(text[i:end], term) {
+			return end
+		}
+	}
+	return i
+}
+
+// highlight escapes text for HTML and wraps the matches in <mark> tags
+func highlight(text string, matches []textMatch) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(html.EscapeString(text[last:match.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[match.start:match.end]))
+		b.WriteString("</mark>")
+		last = match.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// snippet returns the highlighted part of the text around its first match
+func snippet(text string, matches []textMatch) string {
+	if len(text) <= snippetLength {
+		return highlight(text, matches)
+	}
+
+	start := 0
+	if len(matches) > 0 && matches[0].start > snippetContext {
+		start = matches[0].start - snippetContext
+	}
+	end := start + snippetLength
+	if end > len(text) {
+		end, start = len(text), len(text)-snippetLength
+	}
+	// Cut at spaces rather than inside words where possible
+	if start > 0 {
+		if i := strings.IndexByte(text[start:], ' '); i >= 0 && i < snippetContext {
+			start += i + 1
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+			end = start + i
+		}
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	// Keep only the matches that fit inside the snippet, shifted to its start
+	var inside []textMatch
+	for _, match := range matches {
+		if match.start >= start && match.end <= end {
+			inside = append(inside, textMatch{start: match.start - start, end: match.end - start})
+		}
+	}
+
+	result := highlight(text[start:end], inside)
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(text) {
+		result += "…"
+	}
+	return result
+}
+
+// searchResult builds the result for a matched task, highlighting the query's terms
+func searchResult(task models.Task, score float64, query searchQuery) SearchResult {
+	return SearchResult{
+		Task:  task,
+		Score: score,
+		Highlights: SearchHighlights{
+			Name:        highlight(task.Name, findMatches(task.Name, query.terms)),
+			Description: snippet(task.Description, findMatches(task.Description, query.terms)),
+		},
+	}
+}
+
+// scoreTask scores a task against the query the way the text index weighs fields.
+// Tasks that do not match score zero.
+func scoreTask(task *models.Task, query searchQuery) float64 {
+	if len(findMatches(task.Name, query.excluded)) > 0 || len(findMatches(task.Description, query.excluded)) > 0 {
+		return 0
+	}
+	name := len(findMatches(task.Name, query.terms))
+	description := len(findMatches(task.Description, query.terms))
+	return float64(name*searchNameWeight + description*searchDescriptionWeight)
+}
+
+// searchTasksWithIndex ranks tasks with Mongo's text index
+func searchTasksWithIndex(filter bson.M, q string, query searchQuery, limit int) ([]SearchResult, error) {
+	filter = bson.M{"$and": []bson.M{filter, {"$text": bson.M{"$search": q}}}}
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}}).
+		SetLimit(int64(limit))
+
+	var found []struct {
+		models.Task `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err := TaskService.FindWithOptions(filter, opts, &found); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(found))
+	for _, task := range found {
+		results = append(results, searchResult(task.Task, task.Score, query))
+	}
+	return results, nil
+}
+
+// searchTasksInProcess ranks tasks without a text index, for databases that lack one.
+// Candidates are narrowed down in Mongo and scored here.
+func searchTasksInProcess(filter bson.M, query searchQuery, limit int) ([]SearchResult, error) {
+	var matchAny []bson.M
+	for _, term := range query.terms {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}
+		matchAny = append(matchAny, bson.M{fieldName: pattern}, bson.M{fieldDescription: pattern})
+	}
+	filter = bson.M{"$and": []bson.M{filter, {"$or": matchAny}}}
+
+	candidates := []models.Task{}
+	opts := options.Find().SetLimit(maxSearchCandidates)
+	if err := TaskService.FindWithOptions(filter, opts, &candidates); err != nil {
+		return nil, err
+	}
+	return rankTasks(candidates, query, limit), nil
+}
+
+// rankTasks scores the candidates against the query and returns the best matches, highest
+// score first and, among equal scores, most recently updated first
+func rankTasks(candidates []models.Task, query searchQuery, limit int) []SearchResult {
+	results := []SearchResult{}
+	for _, task := range candidates {
+		if score := scoreTask(&task, query); score > 0 {
+			results = append(results, searchResult(task, score, query))
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Task.UpdatedAt.After(results[j].Task.UpdatedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func SearchTasks(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	q := strings.TrimSpace(c.Query(queryText))
+	query := parseSearchQuery(q)
+	if len(query.terms) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errSearchQueryRequired})
+	}
+
+	limit := defaultSearchResults
+	if value := c.Query(queryLimit); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSearchResults {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidSearchLimit})
+		}
+	}
+
+	// Only tasks on boards the user can see are searched
+	boards, err := visibleBoards(c, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+	filter := boardTasksFilter(boards)
+
+	var results []SearchResult
+	if SearchTextIndex {
+		results, err = searchTasksWithIndex(filter, q, query, limit)
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(mongoIndexNotFound) {
+			log.Println("Warning: Task text index missing, searching in process")
+			results, err = searchTasksInProcess(filter, query, limit)
+		}
+	} else {
+		results, err = searchTasksInProcess(filter, query, limit)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	byID := make(map[primitive.ObjectID]*models.Board, len(boards))
+	for i := range boards {
+		byID[boards[i].ID] = &boards[i]
+	}
+	for i := range results {
+		if board, ok := byID[results[i].Task.BoardID]; ok {
+			setDueFlags(board, &results[i].Task)
+		}
+	}
+	return c.JSON(results)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		q        string
+		terms    []string
+		excluded []string
+	}{
+		{q: ""},
+		{q: "   "},
+		{q: "login", terms: []string{"login"}},
+		{q: "login screen", terms: []string{"login", "screen"}},
+		{q: `"login screen" bug`, terms: []string{"login screen", "bug"}},
+		{q: "-draft login", terms: []string{"login"}, excluded: []string{"draft"}},
+		{q: `-"work in progress" done`, terms: []string{"done"}, excluded: []string{"work in progress"}},
+		{q: "pre-market", terms: []string{"pre", "market"}},
+		{q: `"unterminated phrase`, terms: []string{"unterminated phrase"}},
+		{q: `"" - -`},
+		{q: "fix, bugs! (now)", terms: []string{"fix", "bugs", "now"}},
+		{q: "über 日本語 -café", terms: []string{"über", "日本語"}, excluded: []string{"café"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			got := parseSearchQuery(tt.q)
+			if !reflect.DeepEqual(got.terms, tt.terms) || !reflect.DeepEqual(got.excluded, tt.excluded) {
+				t.Errorf("parseSearchQuery(%q) = terms %q, excluded %q; want %q, %q",
+					tt.q, got.terms, got.excluded, tt.terms, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Fix the login screen", []string{"login"}, "Fix the <mark>login</mark> screen"},
+		{"Login fails after logout", []string{"log"}, "<mark>Log</mark>in fails after <mark>log</mark>out"},
+		{"Catalog of blogs", []string{"log"}, "Catalog of blogs"},
+		{"LOGIN and login", []string{"Login"}, "<mark>LOGIN</mark> and <mark>login</mark>"},
+		{"Fix <b>bold</b> & login", []string{"login", "bold"}, "Fix &lt;b&gt;<mark>bold</mark>&lt;/b&gt; &amp; <mark>login</mark>"},
+		{"Login screen redesign", []string{"login screen"}, "<mark>Login screen</mark> redesign"},
+		{"Über die Brücke", []string{"über"}, "<mark>Über</mark> die Brücke"},
+		{"Nothing here", []string{"login"}, "Nothing here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := highlight(tt.text, findMatches(tt.text, tt.terms)); got != tt.want {
+				t.Errorf("highlight(%q, %q) = %q, want %q", tt.text, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	var words []string
+	for i := 0; i < 60; i++ {
+		words = append(words, "filler")
+	}
+	filler := strings.Join(words, " ")
+	long := strings.Join(words[:30], " ") + " needle " + strings.Join(words[30:], " ")
+
+	tests := []struct {
+		name        string
+		text        string
+		terms       []string
+		prefix      bool // starts with an ellipsis
+		suffix      bool // ends with an ellipsis
+		mark        string
+		noSubstring string
+	}{
+		{name: "short text is kept whole", text: "Find the needle", terms: []string{"needle"}, mark: "<mark>needle</mark>"},
+		{name: "long text without a match starts at the beginning", text: filler, terms: []string{"missing"}, suffix: true},
+		{name: "match in the middle", text: long, terms: []string{"needle"}, prefix: true, suffix: true, mark: "<mark>needle</mark>"},
+		{name: "match near the start", text: "needle " + long, terms: []string{"needle"}, suffix: true, mark: "<mark>needle</mark>"},
+		{name: "match at the end", text: filler + " needle", terms: []string{"needle"}, prefix: true, mark: "<mark>needle</mark>"},
+		{name: "multi-byte text", text: strings.Repeat("żółw ", 40) + "needle " + strings.Repeat("żółw ", 40), terms: []string{"needle"}, prefix: true, suffix: true, mark: "<mark>needle</mark>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippet(tt.text, findMatches(tt.text, tt.terms))
+			if !utf8.ValidString(got) {
+				t.Fatalf("snippet is not valid UTF-8: %q", got)
+			}
+			if strings.HasPrefix(got, "…") != tt.prefix {
+				t.Errorf("snippet %q: leading ellipsis = %t, want %t", got, !tt.prefix, tt.prefix)
+			}
+			if strings.HasSuffix(got, "…") != tt.suffix {
+				t.Errorf("snippet %q: trailing ellipsis = %t, want %t", got, !tt.suffix, tt.suffix)
+			}
+			if tt.mark != "" && !strings.Contains(got, tt.mark) {
+				t.Errorf("snippet %q does not contain %q", got, tt.mark)
+			}
+
+			plain := strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(got)
+			if len(tt.text) > snippetLength && len(plain) > snippetLength {
+				t.Errorf("snippet is %d bytes long, want at most %d", len(plain), snippetLength)
+			}
+			if !strings.Contains(tt.text, plain) {
+				t.Errorf("snippet %q is not part of the text", plain)
+			}
+			// Cuts fall between words
+			if tt.prefix && strings.HasPrefix(plain, " ") {
+				t.Errorf("snippet %q starts with a space", plain)
+			}
+			if tt.prefix {
+				if i := strings.Index(tt.text, plain); i > 0 && tt.text[i-1] != ' ' {
+					t.Errorf("snippet %q starts inside a word", plain)
+				}
+			}
+			if tt.suffix {
+				if i := strings.Index(tt.text, plain) + len(plain); i < len(tt.text) && tt.text[i] != ' ' {
+					t.Errorf("snippet %q ends inside a word", plain)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreTask(t *testing.T) {
+	query := parseSearchQuery("login -draft")
+	tests := []struct {
+		name        string
+		task        models.Task
+		description string
+		want        float64
+	}{
+		{name: "name match", task: models.Task{Name: "Login screen"}, want: searchNameWeight},
+		{name: "description match", task: models.Task{Description: "Build the login"}, want: searchDescriptionWeight},
+		{name: "both", task: models.Task{Name: "Login", Description: "login, then login again"}, want: searchNameWeight + 2*searchDescriptionWeight},
+		{name: "excluded in the name", task: models.Task{Name: "Login draft"}, want: 0},
+		{name: "excluded in the description", task: models.Task{Name: "Login", Description: "Draft only"}, want: 0},
+		{name: "no match", task: models.Task{Name: "Catalog"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoreTask(&tt.task, query); got != tt.want {
+				t.Errorf("scoreTask = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankTasks(t *testing.T) {
+	now := time.Now()
+	task := func(name, description string, age time.Duration) models.Task {
+		return models.Task{Name: name, Description: description, UpdatedAt: now.Add(-age)}
+	}
+	candidates := []models.Task{
+		task("Old description match", "fix login", 2*time.Hour),
+		task("Logout", "unrelated", 0),
+		task("Login", "login twice: login", time.Hour),
+		task("Login draft", "login", 0),
+		task("New description match", "fix login", time.Hour),
+		task("Login page", "", 0),
+	}
+	query := parseSearchQuery("login -draft")
+
+	got := rankTasks(candidates, query, 10)
+	var names []string
+	for _, result := range got {
+		names = append(names, result.Task.Name)
+	}
+	want := []string{"Login", "Login page", "New description match", "Old description match"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("rankTasks ranked %q, want %q", names, want)
+	}
+	if got[0].Score != searchNameWeight+2*searchDescriptionWeight {
+		t.Errorf("top score = %v, want %v", got[0].Score, searchNameWeight+2*searchDescriptionWeight)
+	}
+	if got[0].Highlights.Name != "<mark>Login</mark>" {
+		t.Errorf("top name highlight = %q", got[0].Highlights.Name)
+	}
+
+	if limited := rankTasks(candidates, query, 2); len(limited) != 2 || limited[1].Task.Name != "Login page" {
+		t.Errorf("rankTasks with limit 2 returned %d results", len(limited))
+	}
+	if none := rankTasks(candidates, parseSearchQuery("missing"), 10); none == nil || len(none) != 0 {
+		t.Errorf("rankTasks without matches = %#v, want an empty list", none)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// syncCursorSkew is subtracted from the time a sync started when building the next cursor,
+// so that writes still in flight during the sync are picked up by the following one
+const syncCursorSkew = 5 * time.Second
+
+// TombstoneRetention is how long deleted tasks are remembered for syncing clients
+var TombstoneRetention = 30 * 24 * time.Hour
+
+// SetTombstoneRetention sets how long deleted tasks are remembered for syncing clients
+func SetTombstoneRetention(retention time.Duration) {
+	TombstoneRetention = retention
+}
+
+// encodeSyncCursor turns a point in time into an opaque sync cursor
+func encodeSyncCursor(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// decodeSyncCursor parses a cursor produced by encodeSyncCursor
+func decodeSyncCursor(cursor string) (time.Time, error) {
+	millis, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(millis).UTC(), nil
+}
+
+// recordTombstone remembers that a task was deleted
+func recordTombstone(task *models.Task) error {
+	_, err := TombstoneService.InsertOne(models.TaskTombstone{
+		TaskID:    task.ID,
+		BoardID:   task.BoardID,
+		DeletedAt: time.Now().UTC(),
+	})
+	return err
+}
+
+func Sync(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	// The next cursor is taken before reading so that nothing written meanwhile is skipped
+	started := time.Now().UTC()
+
+	// Sync every board the user can see, or a single one
+	boards, err := visibleBoards(c, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	response := SyncResponse{
+		Tasks:    []models.Task{},
+		Deleted:  []models.TaskTombstone{},
+		BoardIDs: make([]primitive.ObjectID, 0, len(boards)),
+	}
+	for _, board := range boards {
+		response.BoardIDs = append(response.BoardIDs, board.ID)
+	}
+
+	taskFilter := bson.M{fieldBoardID: bson.M{"$in": response.BoardIDs}}
+
+	// Without a cursor, or with one older than the remembered deletions, send everything
+	response.Full = true
+	if cursor := c.Query(querySince); cursor != "" {
+		since, err := decodeSyncCursor(cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidSyncCursor})
+		}
+
+		if started.Sub(since) < TombstoneRetention {
+			response.Full = false
+			taskFilter[fieldUpdatedAt] = bson.M{"$gte": since}
+
+			tombstoneFilter := bson.M{
+				fieldBoardID:   bson.M{"$in": response.BoardIDs},
+				fieldDeletedAt: bson.M{"$gte": since},
+			}
+			if err := TombstoneService.Find(tombstoneFilter, &response.Deleted); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+			}
+		}
+	}
+
+	if err := TaskService.Find(taskFilter, &response.Tasks); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	setDueFlagsAll(response.Tasks, boards)
+
+	response.Cursor = encodeSyncCursor(started.Add(-syncCursorSkew))
+	return c.JSON(response)
+}
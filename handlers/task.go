@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/AttFlederX/kanban_board_server/models"
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -8,30 +10,29 @@ import (
 )
 
 func GetTasks(c *fiber.Ctx) error {
-	// Get authenticated user ID from context
-	userID := c.Locals(contextKeyUserID).(string)
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
 
-	// Convert string ID to ObjectID
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	// Find tasks on the boards the authenticated user can see, optionally limited to one board
+	boards, err := visibleBoards(c, userObjectID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+		return sendError(c, err)
 	}
 
-	// Find tasks belonging to the authenticated user
-	tasks := []models.Task{}
-	filter := bson.M{fieldUserID: userObjectID}
-	if err := TaskService.Find(filter, &tasks); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	page, err := queryTasks(c, boards)
+	if err != nil {
+		return sendError(c, err)
 	}
-	return c.JSON(tasks)
+	setDueFlagsAll(page.Tasks, boards)
+	return sendTasks(c, page)
 }
 
 func GetTask(c *fiber.Ctx) error {
-	// Get authenticated user ID
-	userID := c.Locals(contextKeyUserID).(string)
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	userObjectID, err := currentUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+		return sendError(c, err)
 	}
 
 	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
@@ -39,25 +40,20 @@ func GetTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
 	}
 
-	var task models.Task
-	if err := TaskService.FindByID(id, &task); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errTaskNotFound})
-	}
-
-	// Verify task belongs to authenticated user
-	if task.UserID != userObjectID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errAccessDenied})
+	task, board, err := authorizeTask(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
 	}
 
+	setDueFlags(board, task)
+	setTaskETag(c, task)
 	return c.JSON(task)
 }
 
 func CreateTask(c *fiber.Ctx) error {
-	// Get authenticated user ID
-	userID := c.Locals(contextKeyUserID).(string)
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	userObjectID, err := currentUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+		return sendError(c, err)
 	}
 
 	var task models.Task
@@ -65,8 +61,52 @@ func CreateTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
 	}
 
-	// Force task to belong to authenticated user
+	// Task must be placed on a board the authenticated user can edit
+	if task.BoardID.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errBoardIDRequired})
+	}
+
+	board, err := authorizeBoard(task.BoardID, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	// Status must name one of the board's columns
+	status, ok := resolveStatus(board, task.Status)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidStatus})
+	}
+	task.Status = status
+
+	if _, msg := normalizeTaskDates(&task); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	if task.Labels, ok = resolveTaskLabels(board, task.Labels); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errUnknownLabel})
+	}
+
+	if task.Assignees, ok = resolveAssignees(board, task.Assignees); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errAssigneeNotMember})
+	}
+
+	if msg := normalizeChecklist(&task); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// New tasks go to the bottom of their column
+	last, err := lastRank(task.BoardID, task.Status, primitive.NilObjectID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	task.Rank = rankBetween(last, "")
+
+	// Record the authenticated user as the task's creator
 	task.UserID = userObjectID
+	task.CreatedAt = time.Now().UTC()
+	task.UpdatedAt = task.CreatedAt
+	task.Version = 1
+	task.Archived, task.ArchivedAt = false, nil
 
 	id, err := TaskService.InsertOne(task)
 	if err != nil {
@@ -74,19 +114,24 @@ func CreateTask(c *fiber.Ctx) error {
 	}
 
 	task.ID = id
+	setDueFlags(board, &task)
+
+	recordActivity(models.ActivityCreate, &task, userObjectID, nil)
 
 	// Broadcast task creation to websocket clients
-	BroadcastTaskChange("create", id, userObjectID, task)
+	BroadcastTaskChange(messageTypeCreate, board, &task, userObjectID, task)
+	rebalanceColumn(board, &task, userObjectID)
+
+	notifyMentions(board, &task, userObjectID, "", task.Description, nil)
 
+	setTaskETag(c, &task)
 	return c.Status(fiber.StatusCreated).JSON(task)
 }
 
 func UpdateTask(c *fiber.Ctx) error {
-	// Get authenticated user ID
-	userID := c.Locals(contextKeyUserID).(string)
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	userObjectID, err := currentUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+		return sendError(c, err)
 	}
 
 	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
@@ -94,14 +139,14 @@ func UpdateTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
 	}
 
-	// Check if task exists and belongs to user
-	var existingTask models.Task
-	if err := TaskService.FindByID(id, &existingTask); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errTaskNotFound})
+	existingTask, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
 	}
 
-	if existingTask.UserID != userObjectID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errAccessDenied})
+	// Clients that send If-Match only overwrite the version they have seen
+	if err := checkIfMatch(c, existingTask); err != nil {
+		return sendError(c, err)
 	}
 
 	var task models.Task
@@ -109,31 +154,185 @@ func UpdateTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
 	}
 
+	// Status must name one of the board's columns
+	status, ok := resolveStatus(board, task.Status)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidStatus})
+	}
+
+	if _, msg := normalizeTaskDates(&task); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: msg})
+	}
+
+	// Keep the task's position unless it changes column, in which case it goes to the bottom
+	rank := existingTask.Rank
+	if status != existingTask.Status {
+		last, err := lastRank(existingTask.BoardID, status, id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+		rank = rankBetween(last, "")
+	}
+
 	update := bson.M{
 		fieldName:        task.Name,
 		fieldDescription: task.Description,
-		fieldStatus:      task.Status,
-		fieldUserID:      userObjectID,
+		fieldStatus:      status,
+		fieldRank:        rank,
+		fieldStartDate:   task.StartDate,
+		fieldDueDate:     task.DueDate,
+		fieldTimeZone:    task.TimeZone,
 	}
-	if err := TaskService.UpdateByID(id, update); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+
+	// Labels are left alone unless the body lists them; an empty list takes them all off
+	if task.Labels != nil {
+		labels, ok := resolveTaskLabels(board, task.Labels)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errUnknownLabel})
+		}
+		update[fieldLabels] = labels
 	}
 
-	task.ID = id
-	task.UserID = userObjectID
+	before := taskSnapshot(existingTask)
+	previousDescription := existingTask.Description
+	if err := updateTaskVersion(existingTask, update); err != nil {
+		return sendError(c, err)
+	}
+	setDueFlags(board, existingTask)
+
+	recordActivity(models.ActivityUpdate, existingTask, userObjectID, before)
+
+	// Broadcast task update to websocket clients
+	BroadcastTaskChange(messageTypeUpdate, board, existingTask, userObjectID, existingTask)
+	rebalanceColumn(board, existingTask, userObjectID)
+
+	notifyMentions(board, existingTask, userObjectID, previousDescription, existingTask.Description, nil)
+
+	setTaskETag(c, existingTask)
+	return c.JSON(existingTask)
+}
+
+func MoveTask(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	if err := checkIfMatch(c, task); err != nil {
+		return sendError(c, err)
+	}
+
+	var req MoveTaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	// Moves without a status stay in the current column
+	status := task.Status
+	if req.Status != "" {
+		resolved, ok := resolveStatus(board, req.Status)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidStatus})
+		}
+		status = resolved
+	}
+
+	// Anchors must be other tasks in the target column. Anchors that share their rank with
+	// another task get ranks of their own first, so there is room next to them.
+	anchorRank := func(anchorID primitive.ObjectID) (string, bool, error) {
+		var anchor models.Task
+		if anchorID == id || TaskService.FindByID(anchorID, &anchor) != nil {
+			return "", false, nil
+		}
+		if anchor.BoardID != task.BoardID || anchor.Status != status {
+			return "", false, nil
+		}
+		separated, err := separateTiedRanks(board, status, anchor.Rank, id, userObjectID)
+		if err != nil || !separated {
+			return anchor.Rank, true, err
+		}
+		if err := TaskService.FindByID(anchorID, &anchor); err != nil {
+			return "", false, err
+		}
+		return anchor.Rank, true, nil
+	}
+
+	var prev, next string
+	switch {
+	case !req.AfterTaskID.IsZero() && !req.BeforeTaskID.IsZero():
+		var okPrev, okNext bool
+		if prev, okPrev, err = anchorRank(req.AfterTaskID); err != nil {
+			return sendError(c, err)
+		}
+		if next, okNext, err = anchorRank(req.BeforeTaskID); err != nil {
+			return sendError(c, err)
+		}
+		if !okPrev || !okNext || prev >= next {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidMoveAnchor})
+		}
+
+	case !req.AfterTaskID.IsZero():
+		var ok bool
+		if prev, ok, err = anchorRank(req.AfterTaskID); err != nil {
+			return sendError(c, err)
+		}
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidMoveAnchor})
+		}
+		if next, err = neighbourRank(task.BoardID, status, prev, true, id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+
+	case !req.BeforeTaskID.IsZero():
+		var ok bool
+		if next, ok, err = anchorRank(req.BeforeTaskID); err != nil {
+			return sendError(c, err)
+		}
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidMoveAnchor})
+		}
+		if prev, err = neighbourRank(task.BoardID, status, next, false, id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+
+	default:
+		if prev, err = lastRank(task.BoardID, status, id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+		}
+	}
+
+	// Only the moved task is written; its neighbours keep their ranks
+	update := bson.M{fieldStatus: status, fieldRank: rankBetween(prev, next)}
+	before := taskSnapshot(task)
+	if err := updateTaskVersion(task, update); err != nil {
+		return sendError(c, err)
+	}
+	setDueFlags(board, task)
+
+	recordActivity(models.ActivityMove, task, userObjectID, before)
 
 	// Broadcast task update to websocket clients
-	BroadcastTaskChange("update", id, userObjectID, task)
+	BroadcastTaskChange(messageTypeUpdate, board, task, userObjectID, task)
+	rebalanceColumn(board, task, userObjectID)
 
+	setTaskETag(c, task)
 	return c.JSON(task)
 }
 
 func DeleteTask(c *fiber.Ctx) error {
-	// Get authenticated user ID
-	userID := c.Locals(contextKeyUserID).(string)
-	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	userObjectID, err := currentUserID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidUserID})
+		return sendError(c, err)
 	}
 
 	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
@@ -141,22 +340,25 @@ func DeleteTask(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
 	}
 
-	// Check if task exists and belongs to user
-	var task models.Task
-	if err := TaskService.FindByID(id, &task); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{jsonFieldError: errTaskNotFound})
+	task, board, err := authorizeTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
 	}
 
-	if task.UserID != userObjectID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{jsonFieldError: errAccessDenied})
+	if err := checkIfMatch(c, task); err != nil {
+		return sendError(c, err)
 	}
 
-	if err := TaskService.DeleteByID(id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	// The task goes to the board's trash; its comments and attachments stay with it until
+	// it is purged
+	if err := trashTask(task, userObjectID); err != nil {
+		return sendError(c, err)
 	}
 
+	recordActivity(models.ActivityDelete, task, userObjectID, nil)
+
 	// Broadcast task deletion to websocket clients
-	BroadcastTaskChange("delete", id, userObjectID, nil)
+	BroadcastTaskChange(messageTypeDelete, board, task, userObjectID, nil)
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
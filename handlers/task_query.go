@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultTaskPageSize and maxTaskPageSize bound the number of tasks per GET /tasks page
+	defaultTaskPageSize = 100
+	maxTaskPageSize     = 500
+
+	// unpagedTaskLimit stands for no limit when a task list is not paged
+	unpagedTaskLimit = math.MaxInt32 - 1
+
+	// sortPosition orders tasks the way boards display them: by board, column and rank
+	sortPosition = "position"
+
+	// fieldBucket holds a task's position bucket while a page is sorted by position
+	fieldBucket = "_bucket"
+)
+
+// taskQueryFilter turns the query parameters it handles into a filter condition. It returns
+// a nil condition when its parameters are absent.
+type taskQueryFilter func(c *fiber.Ctx, boards []models.Board) (bson.M, error)
+
+// taskQueryFilters build the filter of GET /tasks; every condition they return must hold
+var taskQueryFilters = []taskQueryFilter{
+	statusQueryFilter,
+	textQueryFilter,
+	expressionQueryFilter,
+	flagQueryFilter(queryOverdue, overdueFilter),
+	flagQueryFilter(queryDueSoon, dueSoonFilter),
+	dateRangeQueryFilter(fieldDueDate, queryDueBefore, queryDueAfter),
+	dateRangeQueryFilter(fieldStartDate, queryStartBefore, queryStartAfter),
+	labelQueryFilter,
+	assigneeQueryFilter,
+	archivedQueryFilter,
+}
+
+// taskSort describes a sort key of GET /tasks other than the board position
+type taskSort struct {
+	field string
+	value func(task *models.Task) interface{}
+}
+
+// taskSorts lists the sort keys of GET /tasks by query name. Ties are broken by task ID.
+var taskSorts = map[string]taskSort{
+	"created": {field: fieldCreatedAt, value: func(task *models.Task) interface{} { return task.CreatedAt }},
+	"updated": {field: fieldUpdatedAt, value: func(task *models.Task) interface{} { return task.UpdatedAt }},
+	"name":    {field: fieldName, value: func(task *models.Task) interface{} { return task.Name }},
+}
+
+// taskCursor marks where a page of tasks ended. It is handed to clients as an opaque token.
+type taskCursor struct {
+	Sort  string             `bson:"s"`
+	Value interface{}        `bson:"v"`
+	ID    primitive.ObjectID `bson:"i"`
+
+	// Column bucket of the last task, for the position sort
+	BoardID primitive.ObjectID `bson:"b,omitempty"`
+	Status  string             `bson:"c,omitempty"`
+	Other   bool               `bson:"o,omitempty"`
+}
+
+func encodeTaskCursor(cursor taskCursor) string {
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTaskCursor(token string) (*taskCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor taskCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// boardTasksFilter matches the tasks of the given boards
+func boardTasksFilter(boards []models.Board) bson.M {
+	boardIDs := make([]primitive.ObjectID, 0, len(boards))
+	for _, board := range boards {
+		boardIDs = append(boardIDs, board.ID)
+	}
+	return bson.M{fieldBoardID: bson.M{"$in": boardIDs}}
+}
+
+// splitQueryList splits a comma-separated query parameter into its non-empty values
+func splitQueryList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// statusQueryFilter keeps tasks in any of the listed columns. Column names are matched
+// like task statuses, against every board being listed.
+func statusQueryFilter(c *fiber.Ctx, boards []models.Board) (bson.M, error) {
+	statuses := splitQueryList(c.Query(queryStatus))
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	for _, status := range statuses {
+		matched := false
+		for i := range boards {
+			if name, ok := resolveStatus(&boards[i], status); ok {
+				names = append(names, name)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidStatus)
+		}
+	}
+	return bson.M{fieldStatus: bson.M{"$in": names}}, nil
+}
+
+// textQueryFilter keeps tasks whose name or description contains the given text
+func textQueryFilter(c *fiber.Ctx, _ []models.Board) (bson.M, error) {
+	text := strings.TrimSpace(c.Query(queryText))
+	if text == "" {
+		return nil, nil
+	}
+
+	return containsTextFilter(text), nil
+}
+
+// containsTextFilter matches tasks whose name or description contains the text, ignoring case
+func containsTextFilter(text string) bson.M {
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(text), Options: "i"}
+	return bson.M{"$or": []bson.M{
+		{fieldName: pattern},
+		{fieldDescription: pattern},
+	}}
+}
+
+// afterCursor matches the tasks that come after the cursor in the given sort order
+func afterCursor(field string, value interface{}, id primitive.ObjectID, descending bool) bson.M {
+	op := "$gt"
+	if descending {
+		op = "$lt"
+	}
+	return bson.M{"$or": []bson.M{
+		{field: bson.M{op: value}},
+		{field: value, "_id": bson.M{op: id}},
+	}}
+}
+
+// pagedTaskQuery reports whether the client asked for a page of tasks. Task lists answer
+// requests without limit or cursor with a plain array of every matching task, as they
+// did before they were paged.
+func pagedTaskQuery(c *fiber.Ctx) bool {
+	return c.Query(queryLimit) != "" || c.Query(queryCursor) != ""
+}
+
+// sendTasks answers with the page, or with just its tasks when no page was asked for
+func sendTasks(c *fiber.Ctx, page *TaskPage) error {
+	if !pagedTaskQuery(c) {
+		return c.JSON(page.Tasks)
+	}
+	return c.JSON(page)
+}
+
+// taskPageLimit reads the limit query parameter
+func taskPageLimit(c *fiber.Ctx) (int, error) {
+	if !pagedTaskQuery(c) {
+		return unpagedTaskLimit, nil
+	}
+	value := c.Query(queryLimit)
+	if value == "" {
+		return defaultTaskPageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxTaskPageSize {
+		return 0, fiber.NewError(fiber.StatusBadRequest, errInvalidLimit)
+	}
+	return limit, nil
+}
+
+// findTasksSorted returns a page of tasks ordered by one of taskSorts. A leading "-" in
+// the sort name reverses the order.
+func findTasksSorted(filter []bson.M, sortName string, cursor *taskCursor, limit int) ([]models.Task, string, error) {
+	descending := strings.HasPrefix(sortName, "-")
+	sortKey, ok := taskSorts[strings.TrimPrefix(sortName, "-")]
+	if !ok {
+		return nil, "", fiber.NewError(fiber.StatusBadRequest, errInvalidSort)
+	}
+
+	if cursor != nil {
+		filter = append(filter, afterCursor(sortKey.field, cursor.Value, cursor.ID, descending))
+	}
+
+	direction := 1
+	if descending {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortKey.field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit + 1))
+
+	tasks := []models.Task{}
+	if err := TaskService.FindWithOptions(bson.M{"$and": filter}, opts, &tasks); err != nil {
+		return nil, "", err
+	}
+
+	if len(tasks) <= limit {
+		return tasks, "", nil
+	}
+	tasks = tasks[:limit]
+	last := &tasks[limit-1]
+	return tasks, encodeTaskCursor(taskCursor{Sort: sortName, Value: sortKey.value(last), ID: last.ID}), nil
+}
+
+// positionBucket is one column of one board, or the tasks of a board whose status
+// matches none of its columns
+type positionBucket struct {
+	boardID primitive.ObjectID
+	status  string
+	other   bool
+}
+
+// sortBoardsByID orders boards by ID, and so by creation. Listing tasks by position
+// takes the boards in this order.
+func sortBoardsByID(boards []models.Board) {
+	sort.Slice(boards, func(i, j int) bool {
+		return boards[i].ID.Hex() < boards[j].ID.Hex()
+	})
+}
+
+// positionBuckets lists the column buckets of the boards in display order
+func positionBuckets(boards []models.Board) []positionBucket {
+	ordered := append([]models.Board(nil), boards...)
+	sortBoardsByID(ordered)
+
+	var buckets []positionBucket
+	for i := range ordered {
+		board := &ordered[i]
+		for _, column := range sortedColumns(board) {
+			buckets = append(buckets, positionBucket{boardID: board.ID, status: column.Name})
+		}
+		buckets = append(buckets, positionBucket{boardID: board.ID, other: true})
+	}
+	return buckets
+}
+
+// positionBucketIndex is an aggregation expression for the index of a task's bucket.
+// A board's other bucket comes after its columns, so it only catches the statuses
+// none of them matched.
+func positionBucketIndex(buckets []positionBucket) bson.M {
+	branches := make([]bson.M, 0, len(buckets))
+	for i, bucket := range buckets {
+		match := bson.M{"$eq": bson.A{"$" + fieldBoardID, bucket.boardID}}
+		if !bucket.other {
+			match = bson.M{"$and": bson.A{match, bson.M{"$eq": bson.A{"$" + fieldStatus, bucket.status}}}}
+		}
+		branches = append(branches, bson.M{"case": match, "then": i})
+	}
+	return bson.M{"$switch": bson.M{"branches": branches, "default": len(buckets)}}
+}
+
+// findTasksByPosition returns a page of tasks in board order: bucket by bucket, and by
+// rank within a bucket. The page is read with a single aggregation, however many
+// columns it spans or skips.
+func findTasksByPosition(filter []bson.M, boards []models.Board, cursor *taskCursor, limit int) ([]models.Task, string, error) {
+	buckets := positionBuckets(boards)
+
+	start := 0
+	if cursor != nil {
+		start = -1
+		for i, bucket := range buckets {
+			if bucket.boardID == cursor.BoardID && bucket.status == cursor.Status && bucket.other == cursor.Other {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return nil, "", fiber.NewError(fiber.StatusBadRequest, errInvalidPageCursor)
+		}
+	}
+	if len(buckets) == 0 {
+		return []models.Task{}, "", nil
+	}
+
+	// Boards before the cursor's board are done with
+	var boardIDs []primitive.ObjectID
+	for i := start; i < len(buckets); i++ {
+		if buckets[i].other {
+			boardIDs = append(boardIDs, buckets[i].boardID)
+		}
+	}
+	filter = append(filter, bson.M{fieldBoardID: bson.M{"$in": boardIDs}})
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"$and": filter}},
+		bson.M{"$addFields": bson.M{fieldBucket: positionBucketIndex(buckets)}},
+	}
+	if cursor != nil {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"$or": []bson.M{
+			{fieldBucket: bson.M{"$gt": start}},
+			{fieldBucket: start, fieldRank: bson.M{"$gt": cursor.Value}},
+			{fieldBucket: start, fieldRank: cursor.Value, "_id": bson.M{"$gt": cursor.ID}},
+		}}})
+	}
+	pipeline = append(pipeline,
+		bson.M{"$sort": bson.D{{Key: fieldBucket, Value: 1}, {Key: fieldRank, Value: 1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit + 1},
+	)
+
+	var found []struct {
+		models.Task `bson:",inline"`
+		Bucket      int `bson:"_bucket"`
+	}
+	if err := TaskService.Aggregate(pipeline, &found); err != nil {
+		return nil, "", err
+	}
+
+	tasks := make([]models.Task, 0, len(found))
+	for _, task := range found {
+		tasks = append(tasks, task.Task)
+	}
+	if len(tasks) <= limit {
+		return tasks, "", nil
+	}
+	tasks = tasks[:limit]
+	last := &found[limit-1]
+	lastBucket := buckets[last.Bucket]
+	next := taskCursor{
+		Sort:    sortPosition,
+		Value:   last.Rank,
+		ID:      last.ID,
+		BoardID: lastBucket.boardID,
+		Status:  lastBucket.status,
+		Other:   lastBucket.other,
+	}
+	return tasks, encodeTaskCursor(next), nil
+}
+
+// queryTasks lists the tasks of the boards that match the request's filters and the given
+// conditions, one page at a time or all at once when no page was asked for
+func queryTasks(c *fiber.Ctx, boards []models.Board, conditions ...bson.M) (*TaskPage, error) {
+	filter := append([]bson.M{boardTasksFilter(boards)}, conditions...)
+
+	for _, build := range taskQueryFilters {
+		condition, err := build(c, boards)
+		if err != nil {
+			return nil, err
+		}
+		if condition != nil {
+			filter = append(filter, condition)
+		}
+	}
+
+	limit, err := taskPageLimit(c)
+	if err != nil {
+		return nil, err
+	}
+
+	sortName := c.Query(querySort, sortPosition)
+	var cursor *taskCursor
+	if token := c.Query(queryCursor); token != "" {
+		if cursor, err = decodeTaskCursor(token); err != nil || cursor.Sort != sortName {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidPageCursor)
+		}
+	}
+
+	page := &TaskPage{}
+	if sortName == sortPosition {
+		page.Tasks, page.Next, err = findTasksByPosition(filter, boards, cursor, limit)
+		return page, err
+	}
+
+	page.Tasks, page.Next, err = findTasksSorted(filter, sortName, cursor, limit)
+	return page, err
+}
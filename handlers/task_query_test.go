@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPositionBuckets(t *testing.T) {
+	older, newer := primitive.NewObjectID(), primitive.NewObjectID()
+	boards := []models.Board{
+		{ID: newer, Columns: []models.Column{{Name: "Done", Order: 1}, {Name: "Doing", Order: 0}}},
+		{ID: older, Columns: []models.Column{{Name: "To Do", Order: 0}}},
+	}
+
+	want := []positionBucket{
+		{boardID: older, status: "To Do"},
+		{boardID: older, other: true},
+		{boardID: newer, status: "Doing"},
+		{boardID: newer, status: "Done"},
+		{boardID: newer, other: true},
+	}
+	if got := positionBuckets(boards); !reflect.DeepEqual(got, want) {
+		t.Errorf("positionBuckets = %+v, want %+v", got, want)
+	}
+
+	// Sorting the buckets must leave the caller's boards alone
+	if boards[0].ID != newer {
+		t.Error("positionBuckets reordered its argument")
+	}
+}
+
+func TestPositionBucketIndex(t *testing.T) {
+	board := primitive.NewObjectID()
+	buckets := []positionBucket{
+		{boardID: board, status: "To Do"},
+		{boardID: board, other: true},
+	}
+
+	expr := positionBucketIndex(buckets)["$switch"].(bson.M)
+	branches := expr["branches"].([]bson.M)
+	if len(branches) != len(buckets) {
+		t.Fatalf("positionBucketIndex has %d branches, want %d", len(branches), len(buckets))
+	}
+	for i, branch := range branches {
+		if branch["then"] != i {
+			t.Errorf("branch %d yields %v", i, branch["then"])
+		}
+	}
+
+	// A column matches on board and status; the other bucket on the board alone
+	column := bson.M{"$and": bson.A{
+		bson.M{"$eq": bson.A{"$boardId", board}},
+		bson.M{"$eq": bson.A{"$status", "To Do"}},
+	}}
+	if !reflect.DeepEqual(branches[0]["case"], column) {
+		t.Errorf("column branch matches %v, want %v", branches[0]["case"], column)
+	}
+	other := bson.M{"$eq": bson.A{"$boardId", board}}
+	if !reflect.DeepEqual(branches[1]["case"], other) {
+		t.Errorf("other branch matches %v, want %v", branches[1]["case"], other)
+	}
+	if expr["default"] != len(buckets) {
+		t.Errorf("default bucket is %v, want %d", expr["default"], len(buckets))
+	}
+}
+
+func TestSendTasks(t *testing.T) {
+	app := fiber.New()
+	app.Get("/tasks", func(c *fiber.Ctx) error {
+		limit, err := taskPageLimit(c)
+		if err != nil {
+			return sendError(c, err)
+		}
+		c.Set("X-Limit", strconv.Itoa(limit))
+		return sendTasks(c, &TaskPage{Tasks: []models.Task{}, Next: "abc"})
+	})
+
+	tests := []struct {
+		query string
+		limit int
+		body  string
+	}{
+		{"", unpagedTaskLimit, `[]`},
+		{"?status=done&sort=name", unpagedTaskLimit, `[]`},
+		{"?limit=2", 2, `{"tasks":[],"next":"abc"}`},
+		{"?cursor=xyz", defaultTaskPageSize, `{"tasks":[],"next":"abc"}`},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/tasks"+tt.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if got := strings.TrimSpace(string(body)); got != tt.body {
+			t.Errorf("GET /tasks%s = %s, want %s", tt.query, got, tt.body)
+		}
+		if got := resp.Header.Get("X-Limit"); got != strconv.Itoa(tt.limit) {
+			t.Errorf("GET /tasks%s reads limit %s, want %d", tt.query, got, tt.limit)
+		}
+	}
+}
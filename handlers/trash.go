@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrashRetention is how long deleted tasks stay in their board's trash before they are
+// purged together with their comments and attachments
+var TrashRetention = 30 * 24 * time.Hour
+
+// SetTrashRetention sets how long deleted tasks can be restored
+func SetTrashRetention(retention time.Duration) {
+	TrashRetention = retention
+}
+
+// trashTask moves the task into its board's trash. Like deleteTaskVersion it fails with
+// 412 Precondition Failed when the task was changed or deleted meanwhile. The task is
+// deleted before it is trashed, so of two racing deletes only one gets that far.
+func trashTask(task *models.Task, userID primitive.ObjectID) error {
+	if err := deleteTaskVersion(task); err != nil {
+		return err
+	}
+
+	if _, err := TrashService.InsertOne(models.TrashedTask{
+		Task:      *task,
+		DeletedAt: time.Now().UTC(),
+		DeletedBy: userID,
+	}); err != nil {
+		// Put the task back rather than lose it
+		if _, err := TaskService.InsertOne(task); err != nil {
+			log.Printf("Error putting task %s back after trashing it failed: %v", task.ID.Hex(), err)
+		}
+		return err
+	}
+
+	// Leave a tombstone so that offline clients learn about the deletion on their next sync
+	return recordTombstone(task)
+}
+
+// restoreTask puts a trashed task back on its board, at the bottom of its column. Tasks
+// whose column is gone go to the first column, and labels and assignees the board no
+// longer has are dropped.
+func restoreTask(entry *models.TrashedTask, board *models.Board) (*models.Task, error) {
+	task := entry.Task
+
+	status, ok := resolveStatus(board, task.Status)
+	if !ok {
+		status, _ = resolveStatus(board, "")
+	}
+	task.Status = status
+
+	labels := []primitive.ObjectID{}
+	for _, labelID := range task.Labels {
+		if findLabel(board, labelID) >= 0 {
+			labels = append(labels, labelID)
+		}
+	}
+	task.Labels = labels
+
+	assignees := []primitive.ObjectID{}
+	for _, userID := range task.Assignees {
+		if board.RoleOf(userID) != "" {
+			assignees = append(assignees, userID)
+		}
+	}
+	task.Assignees = assignees
+
+	last, err := lastRank(task.BoardID, task.Status, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	task.Rank = rankBetween(last, "")
+	task.Version++
+	task.UpdatedAt = time.Now().UTC()
+
+	if _, err := TaskService.InsertOne(task); err != nil {
+		// Someone else restored it first
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fiber.NewError(fiber.StatusNotFound, errTrashedTaskNotFound)
+		}
+		return nil, err
+	}
+	if err := TrashService.DeleteByID(task.ID); err != nil {
+		return nil, err
+	}
+
+	// Clients that already synced the deletion get the task back as a change
+	if err := TombstoneService.DeleteMany(bson.M{fieldTaskID: task.ID}); err != nil {
+		return nil, err
+	}
+
+	setDueFlags(board, &task)
+	return &task, nil
+}
+
+// findTrashedTask loads a task from the trash and checks the user's role on its board
+func findTrashedTask(taskID, userID primitive.ObjectID, role string) (*models.TrashedTask, *models.Board, error) {
+	var entry models.TrashedTask
+	if err := TrashService.FindByID(taskID, &entry); err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, errTrashedTaskNotFound)
+	}
+
+	board, err := authorizeBoard(entry.BoardID, userID, role)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entry, board, nil
+}
+
+// GetTrash lists the deleted tasks of a board, most recently deleted first
+func GetTrash(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	board, err := authorizeBoard(id, userObjectID, models.RoleViewer)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	trash := []models.TrashedTask{}
+	opts := options.Find().SetSort(bson.D{{Key: fieldDeletedAt, Value: -1}, {Key: "_id", Value: -1}})
+	if err := TrashService.FindWithOptions(bson.M{fieldBoardID: id}, opts, &trash); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	for i := range trash {
+		trash[i].PurgeAt = trash[i].DeletedAt.Add(TrashRetention)
+		setDueFlags(board, &trash[i].Task)
+	}
+
+	return c.JSON(trash)
+}
+
+// RestoreTask takes a task out of the trash
+func RestoreTask(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Params(jsonFieldID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{jsonFieldError: errInvalidID})
+	}
+
+	entry, board, err := findTrashedTask(id, userObjectID, models.RoleEditor)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	task, err := restoreTask(entry, board)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	recordActivity(models.ActivityRestore, task, userObjectID, nil)
+
+	// To other clients the task reappears as a new one
+	BroadcastTaskChange(messageTypeCreate, board, task, userObjectID, task)
+	rebalanceColumn(board, task, userObjectID)
+
+	setTaskETag(c, task)
+	return c.JSON(task)
+}
+
+// PurgeTrash deletes the tasks that have been in the trash for longer than the retention,
+// together with their comments, attachments and the notifications about them
+func PurgeTrash(now time.Time) error {
+	var expired []models.TrashedTask
+	if err := TrashService.Find(bson.M{fieldDeletedAt: bson.M{"$lte": now.Add(-TrashRetention)}}, &expired); err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(expired))
+	for _, entry := range expired {
+		ids = append(ids, entry.ID)
+	}
+	// The tasks leave the trash first, so that they can no longer be restored without
+	// their comments and attachments
+	if err := TrashService.DeleteMany(bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+
+	byTask := bson.M{fieldTaskID: bson.M{"$in": ids}}
+	if err := CommentService.DeleteMany(byTask); err != nil {
+		return err
+	}
+	if err := NotificationService.DeleteMany(byTask); err != nil {
+		return err
+	}
+	if err := deleteAttachments(byTask); err != nil {
+		return err
+	}
+
+	log.Printf("Purged %d tasks from the trash", len(expired))
+	return nil
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/storage"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,10 +23,267 @@ type AuthResponse struct {
 	User  models.User `json:"user"`
 }
 
+// ColumnRequest represents the request body for creating or renaming a board column
+type ColumnRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// ChecklistItemRequest represents the request body for adding a checklist item
+type ChecklistItemRequest struct {
+	Text string `json:"text"`
+}
+
+// ChecklistItemUpdateRequest represents the request body for changing a checklist item.
+// Omitted fields keep their current value.
+type ChecklistItemUpdateRequest struct {
+	Text *string `json:"text"`
+	Done *bool   `json:"done"`
+}
+
+// ReorderChecklistRequest represents the request body for reordering a task's checklist
+type ReorderChecklistRequest struct {
+	ItemIDs []primitive.ObjectID `json:"itemIds"`
+}
+
+// ChecklistEvent is the payload of a checklist websocket message. It carries the whole
+// checklist, so clients can replace theirs without replaying every change.
+type ChecklistEvent struct {
+	Action    string                   `json:"action"` // "add", "update", "reorder", "remove"
+	ItemID    string                   `json:"itemId,omitempty"`
+	Checklist []models.ChecklistItem   `json:"checklist"`
+	Progress  models.ChecklistProgress `json:"checklistProgress"`
+	UpdatedAt time.Time                `json:"updatedAt"`
+}
+
+// CommentRequest represents the request body for writing or editing a comment
+type CommentRequest struct {
+	Text string `json:"text"`
+}
+
+// CommentEvent is the payload of a comment websocket message
+type CommentEvent struct {
+	Action  string          `json:"action"` // "create", "update", "delete"
+	Comment *models.Comment `json:"comment"`
+}
+
+// ColumnEvent is the payload of a columns websocket message. It carries all of the
+// board's columns in display order, so clients can replace theirs.
+type ColumnEvent struct {
+	Action      string          `json:"action"` // "create", "update", "reorder", "delete"
+	ColumnID    string          `json:"columnId,omitempty"`
+	RenamedFrom string          `json:"renamedFrom,omitempty"` // Previous name of a renamed column, whose tasks moved along
+	Columns     []models.Column `json:"columns"`
+}
+
+// LabelEvent is the payload of a labels websocket message. It carries the board's whole
+// label catalogue, so clients can replace theirs.
+type LabelEvent struct {
+	Action  string         `json:"action"` // "create", "update", "delete"
+	LabelID string         `json:"labelId"`
+	Labels  []models.Label `json:"labels"`
+}
+
+// MemberEvent is the payload of a members websocket message. It carries all of the
+// board's members, so clients can replace their list.
+type MemberEvent struct {
+	Action  string               `json:"action"` // "add", "update", "remove"
+	UserID  string               `json:"userId"` // The member added, changed or removed
+	Members []models.BoardMember `json:"members"`
+}
+
+// AttachmentEvent is the payload of an attachment websocket message
+type AttachmentEvent struct {
+	Action     string             `json:"action"` // "create", "delete"
+	Attachment *models.Attachment `json:"attachment"`
+}
+
+// UndoResponse reports the change that was undone and the task as it now stands, which is
+// null when undoing removed it
+type UndoResponse struct {
+	Undone *models.Activity `json:"undone"`
+	Task   *models.Task     `json:"task"`
+}
+
+// ArchiveTasksRequest represents the request body for archiving a board's finished tasks
+type ArchiveTasksRequest struct {
+	OlderThanDays *int `json:"olderThanDays"`
+}
+
+// ArchiveTasksResponse lists the tasks archived in bulk
+type ArchiveTasksResponse struct {
+	Archived int                  `json:"archived"`
+	TaskIDs  []primitive.ObjectID `json:"taskIds"`
+}
+
+// NotificationRequest represents the request body for marking a notification read or unread
+type NotificationRequest struct {
+	Read *bool `json:"read"`
+}
+
+// NotificationPage is a page of the caller's inbox
+type NotificationPage struct {
+	Notifications []models.Notification `json:"notifications"`
+	Unread        int64                 `json:"unreadCount"` // Unread notifications in the whole inbox
+}
+
+// AssigneeRequest represents the request body for assigning a user to a task
+type AssigneeRequest struct {
+	UserID primitive.ObjectID `json:"userId"`
+}
+
+// LabelRequest represents the request body for creating or updating a board label
+type LabelRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// ReorderColumnsRequest represents the request body for reordering board columns
+type ReorderColumnsRequest struct {
+	ColumnIDs []primitive.ObjectID `json:"columnIds"`
+}
+
+// MoveTaskRequest represents the request body for moving a task. The task is placed
+// between the anchors; omitting both places it at the bottom of the column.
+type MoveTaskRequest struct {
+	Status       string             `json:"status"`
+	AfterTaskID  primitive.ObjectID `json:"afterTaskId"`
+	BeforeTaskID primitive.ObjectID `json:"beforeTaskId"`
+}
+
+// MemberRequest represents the request body for inviting a board member or changing their
+// role. Invitees are identified by user ID or email.
+type MemberRequest struct {
+	UserID primitive.ObjectID `json:"userId"`
+	Email  string             `json:"email"`
+	Role   string             `json:"role"`
+}
+
+// SavedFilterRequest represents the request body for saving a named task query
+type SavedFilterRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// ReminderPreferencesRequest represents the request body for changing reminder preferences.
+// Omitted fields keep their current value.
+type ReminderPreferencesRequest struct {
+	Enabled     *bool `json:"enabled"`
+	LeadMinutes []int `json:"leadMinutes"`
+}
+
+// ReminderData is the payload of a reminder websocket message
+type ReminderData struct {
+	TaskName    string    `json:"taskName"`
+	DueDate     time.Time `json:"dueDate"`
+	LeadMinutes int       `json:"leadMinutes"`
+}
+
+// TaskPage represents one page of GET /tasks
+type TaskPage struct {
+	Tasks []models.Task `json:"tasks"`
+
+	// Cursor of the following page; empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// SearchResult represents a task found by /search
+type SearchResult struct {
+	Task       models.Task      `json:"task"`
+	Score      float64          `json:"score"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+// SearchHighlights holds HTML-escaped task text with the matched words wrapped in <mark> tags
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"` // Snippet around the first match
+}
+
+// SyncResponse represents the tasks changed and deleted since a sync cursor
+type SyncResponse struct {
+	// Tasks created or changed since the cursor
+	Tasks []models.Task `json:"tasks"`
+
+	// Tasks deleted since the cursor
+	Deleted []models.TaskTombstone `json:"deleted"`
+
+	// Boards covered by the sync; local tasks of any other board should be dropped
+	BoardIDs []primitive.ObjectID `json:"boardIds"`
+
+	// Whether this is a full snapshot that replaces all local tasks
+	Full bool `json:"full"`
+
+	// Cursor to pass as "since" on the next sync
+	Cursor string `json:"cursor"`
+}
+
 // Client represents a websocket client connection
 type Client struct {
 	Conn   *websocket.Conn
 	UserID primitive.ObjectID
+
+	// ID and expiry of the token the connection was opened with
+	tokenID        string
+	tokenExpiresAt time.Time
+
+	// Time of the last message received from the client, in Unix nanoseconds
+	lastActivity atomic.Int64
+
+	// Boards the client subscribed to; nil until the first subscription request,
+	// meaning every board the user is a member of
+	boards map[primitive.ObjectID]bool
+
+	// Outbound messages, drained by the client's writer goroutine
+	send chan Message
+
+	// Close frame sent once the queue is closed
+	closeCode   int
+	closeReason string
+
+	// Mutex guarding the send queue against use after it was closed, and delivered
+	mu     sync.Mutex
+	closed bool
+
+	// Sequence number of the last event broadcast to the client per board, so that events
+	// replayed on subscribing are not broadcast to it again
+	delivered map[primitive.ObjectID]int64
+}
+
+// AttachmentConfig holds the attachment settings
+type AttachmentConfig struct {
+	// Where attachment files are kept
+	Storage storage.Storage
+
+	// Largest file accepted, in bytes
+	MaxSize int64
+
+	// Content types that may be uploaded, exact or as "type/*"
+	Types []string
+
+	// How long signed download URLs stay valid
+	URLTTL time.Duration
+}
+
+// HubConfig holds the tunables of the websocket hub
+type HubConfig struct {
+	// Messages queued for a client before it is dropped as a slow consumer
+	SendQueueSize int
+
+	// Events queued for fan-out before further broadcasts are discarded
+	BroadcastBuffer int
+
+	// Interval between server pings
+	PingInterval time.Duration
+
+	// Time allowed for a pong before the connection is considered dead
+	PongTimeout time.Duration
+
+	// Time without client messages before the connection is closed; zero disables it
+	IdleTimeout time.Duration
+
+	// Events kept per board for replay to reconnecting clients
+	EventLogSize int
 }
 
 // Hub maintains the set of active clients and broadcasts messages to the clients
@@ -31,8 +291,8 @@ type Hub struct {
 	// Registered clients mapped by user ID
 	clients map[primitive.ObjectID]map[*Client]bool
 
-	// Inbound messages from the clients
-	broadcast chan Message
+	// Board events to fan out to the clients
+	broadcast chan boardEvent
 
 	// Register requests from the clients
 	register chan *Client
@@ -42,14 +302,33 @@ type Hub struct {
 
 	// Mutex for thread-safe access to clients map
 	mu sync.RWMutex
+
+	config HubConfig
+}
+
+// boardEvent is a message addressed to the members of a board
+type boardEvent struct {
+	boardID primitive.ObjectID
+	members map[primitive.ObjectID]bool
+	message Message
 }
 
 // Message represents a websocket message about task changes
 type Message struct {
-	Type   string      `json:"type"` // "create", "update", "delete"
-	TaskID string      `json:"taskId"`
-	UserID string      `json:"userId"`
-	Data   interface{} `json:"data,omitempty"`
+	Type    string      `json:"type"`          // "create", "update", "patch", "delete", "subscribed", "unsubscribed", "resync_required", "error"
+	Seq     int64       `json:"seq,omitempty"` // Per-board event sequence number
+	TaskID  string      `json:"taskId,omitempty"`
+	Version int64       `json:"version,omitempty"` // Task version after the change
+	BoardID string      `json:"boardId,omitempty"`
+	UserID  string      `json:"userId,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// ClientMessage represents a request sent by a websocket client
+type ClientMessage struct {
+	Action  string `json:"action"` // "subscribe", "unsubscribe"
+	BoardID string `json:"boardId"`
+	Since   *int64 `json:"since"` // Sequence number of the last event seen, to replay missed events
 }
 
 // Claims represents JWT token claims
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lastUndoableActivity finds the caller's most recent task change that has not been undone,
+// optionally on a single board
+func lastUndoableActivity(c *fiber.Ctx, userID primitive.ObjectID) (*models.Activity, error) {
+	filter := bson.M{fieldUserID: userID, fieldUndone: bson.M{"$ne": true}, fieldAction: bson.M{"$ne": models.ActivityUndo}}
+	if value := c.Query(queryBoardID); value != "" {
+		boardID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, errInvalidID)
+		}
+		filter[fieldBoardID] = boardID
+	}
+
+	var found []models.Activity
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(1)
+	if err := ActivityService.FindWithOptions(filter, opts, &found); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fiber.NewError(fiber.StatusNotFound, errNothingToUndo)
+	}
+	return &found[0], nil
+}
+
+// changedSince reports whether anyone changed the task after the activity, not counting
+// changes that were undone again
+func changedSince(activity *models.Activity) (bool, error) {
+	later, err := ActivityService.Count(bson.M{
+		fieldTaskID: activity.TaskID,
+		"_id":       bson.M{"$gt": activity.ID},
+		fieldUndone: bson.M{"$ne": true},
+		fieldAction: bson.M{"$ne": models.ActivityUndo},
+	})
+	return later > 0, err
+}
+
+// revertChanges writes the previous values of the activity's changes back to the task.
+// Values the board no longer accepts, such as a deleted column or label, are returned as
+// field errors and nothing is written.
+func revertChanges(activity *models.Activity, task *models.Task, board *models.Board, userID primitive.ObjectID) (map[string]string, error) {
+	patch := make(map[string]json.RawMessage)
+	var checklist []models.ChecklistItem
+	revertChecklist := false
+	for _, change := range activity.Changes {
+		if change.Field == fieldChecklist {
+			if err := json.Unmarshal(change.From, &checklist); err != nil {
+				return nil, err
+			}
+			revertChecklist = true
+			continue
+		}
+		if _, ok := patchableTaskFields[change.Field]; ok {
+			patch[change.Field] = change.From
+		}
+	}
+
+	patched, changed, fieldErrors := applyTaskPatch(board, task, patch)
+	if fieldErrors != nil {
+		return fieldErrors, nil
+	}
+	update, err := patchUpdate(patched, changed)
+	if err != nil {
+		return nil, err
+	}
+	if revertChecklist {
+		if checklist == nil {
+			checklist = []models.ChecklistItem{}
+		}
+		update[fieldChecklist] = checklist
+		update[fieldChecklistProgress] = checklistProgress(checklist)
+	}
+	if activity.PreviousRank != "" {
+		update[fieldRank] = activity.PreviousRank
+	}
+
+	if len(update) > 0 {
+		before := taskSnapshot(task)
+		if err := updateTaskVersion(task, update); err != nil {
+			return nil, err
+		}
+		recordActivity(models.ActivityUndo, task, userID, before)
+		setDueFlags(board, task)
+		BroadcastTaskChange(messageTypeUpdate, board, task, userID, task)
+		rebalanceColumn(board, task, userID)
+	}
+	return nil, nil
+}
+
+// UndoLastAction reverts the caller's most recent task change, using the task's history:
+// edits and moves are rolled back, created or restored tasks go to the trash, deleted
+// ones come back out of it and archiving is reversed. Repeating the call undoes earlier
+// changes in turn. Changes that someone has built on since cannot be undone.
+func UndoLastAction(c *fiber.Ctx) error {
+	userObjectID, err := currentUserID(c)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	activity, err := lastUndoableActivity(c, userObjectID)
+	if err != nil {
+		return sendError(c, err)
+	}
+
+	changed, err := changedSince(activity)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+	if changed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errUndoConflict})
+	}
+
+	response := UndoResponse{Undone: activity}
+	switch activity.Action {
+	case models.ActivityDelete:
+		entry, board, err := findTrashedTask(activity.TaskID, userObjectID, models.RoleEditor)
+		if err != nil {
+			return sendError(c, undoLookupError(err))
+		}
+		task, err := restoreTask(entry, board)
+		if err != nil {
+			return sendError(c, err)
+		}
+		recordActivity(models.ActivityUndo, task, userObjectID, nil)
+		BroadcastTaskChange(messageTypeCreate, board, task, userObjectID, task)
+		rebalanceColumn(board, task, userObjectID)
+		response.Task = task
+
+	case models.ActivityCreate, models.ActivityRestore:
+		task, board, err := authorizeTask(activity.TaskID, userObjectID, models.RoleEditor)
+		if err != nil {
+			return sendError(c, undoLookupError(err))
+		}
+		if err := trashTask(task, userObjectID); err != nil {
+			return sendError(c, err)
+		}
+		recordActivity(models.ActivityUndo, task, userObjectID, nil)
+		BroadcastTaskChange(messageTypeDelete, board, task, userObjectID, nil)
+
+	case models.ActivityArchive, models.ActivityUnarchive:
+		task, board, err := authorizeTask(activity.TaskID, userObjectID, models.RoleEditor)
+		if err != nil {
+			return sendError(c, undoLookupError(err))
+		}
+		if err := setArchived(task, board, userObjectID, activity.Action == models.ActivityUnarchive, models.ActivityUndo); err != nil {
+			return sendError(c, err)
+		}
+		setDueFlags(board, task)
+		response.Task = task
+
+	default:
+		task, board, err := authorizeTask(activity.TaskID, userObjectID, models.RoleEditor)
+		if err != nil {
+			return sendError(c, undoLookupError(err))
+		}
+		fieldErrors, err := revertChanges(activity, task, board, userObjectID)
+		if err != nil {
+			return sendError(c, err)
+		}
+		if fieldErrors != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{jsonFieldError: errUndoConflict, jsonFieldFields: fieldErrors})
+		}
+		response.Task = task
+	}
+
+	activity.Undone = true
+	if err := ActivityService.UpdateByID(activity.ID, bson.M{fieldUndone: true}); err != nil {
+		log.Printf("Error marking activity %s as undone: %v", activity.ID.Hex(), err)
+	}
+
+	if response.Task != nil {
+		setTaskETag(c, response.Task)
+	}
+	return c.JSON(response)
+}
+
+// undoLookupError reports a task that is no longer where the change left it, for example
+// because it was purged from the trash, as a conflict
+func undoLookupError(err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+		return fiber.NewError(fiber.StatusConflict, errUndoConflict)
+	}
+	return err
+}
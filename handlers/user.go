@@ -66,5 +66,13 @@ func DeleteUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
 	}
 
+	if err := ReminderPreferenceService.DeleteMany(bson.M{fieldUserID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
+	if err := NotificationService.DeleteMany(bson.M{fieldUserID: id}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{jsonFieldError: err.Error()})
+	}
+
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
+	"strconv"
+	"time"
 
+	"github.com/AttFlederX/kanban_board_server/models"
+	"github.com/AttFlederX/kanban_board_server/services"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,6 +16,9 @@ import (
 
 var hub *Hub
 
+// writeWait is the time allowed to write a message to a client
+const writeWait = 10 * time.Second
+
 // JWTSecret stores the JWT secret for token validation
 var JWTSecret string
 
@@ -19,40 +27,228 @@ func SetJWTSecret(secret string) {
 	JWTSecret = secret
 }
 
-// validateTokenAndGetUserID validates JWT token and returns user ID
-func validateTokenAndGetUserID(tokenString string) (primitive.ObjectID, error) {
+// validateTokenAndGetUserID validates JWT token and returns user ID along with the token claims
+func validateTokenAndGetUserID(tokenString string) (primitive.ObjectID, *Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(JWTSecret), nil
 	})
 
 	if err != nil || !token.Valid {
-		return primitive.NilObjectID, errors.New("invalid or expired token")
+		return primitive.NilObjectID, nil, errors.New("invalid or expired token")
 	}
 
 	claims, ok := token.Claims.(*Claims)
 	if !ok {
-		return primitive.NilObjectID, errors.New("invalid token claims")
+		return primitive.NilObjectID, nil, errors.New("invalid token claims")
+	}
+
+	if claims.ID != "" {
+		revoked, err := services.IsTokenRevoked(claims.ID)
+		if err != nil {
+			return primitive.NilObjectID, nil, err
+		}
+		if revoked {
+			return primitive.NilObjectID, nil, errors.New("token has been revoked")
+		}
 	}
 
 	userID, err := primitive.ObjectIDFromHex(claims.UserID)
 	if err != nil {
-		return primitive.NilObjectID, errors.New("invalid user ID in token")
+		return primitive.NilObjectID, nil, errors.New("invalid user ID in token")
 	}
 
-	return userID, nil
+	return userID, claims, nil
 }
 
 // InitHub initializes the websocket hub
-func InitHub() {
+func InitHub(config HubConfig) {
 	hub = &Hub{
-		broadcast:  make(chan Message),
+		broadcast:  make(chan boardEvent, config.BroadcastBuffer),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[primitive.ObjectID]map[*Client]bool),
+		config:     config,
 	}
 	go hub.run()
 }
 
+// newClient creates a client with an outbound queue sized by the hub configuration
+func (h *Hub) newClient(conn *websocket.Conn, userID primitive.ObjectID, claims *Claims) *Client {
+	client := &Client{
+		Conn:    conn,
+		UserID:  userID,
+		tokenID: claims.ID,
+		send:    make(chan Message, h.config.SendQueueSize),
+	}
+	if claims.ExpiresAt != nil {
+		client.tokenExpiresAt = claims.ExpiresAt.Time
+	}
+	client.touch()
+	return client
+}
+
+// touch records that the client just sent a message
+func (c *Client) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long ago the client last sent a message
+func (c *Client) idleFor() time.Duration {
+	return time.Since(time.Unix(0, c.lastActivity.Load()))
+}
+
+// enqueue queues a message for the client without blocking. It returns false when the
+// queue is full or already closed.
+func (c *Client) enqueue(message Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueueEvent queues a broadcast board event like enqueue, skipping events at or below
+// the last sequence number already queued or skipped for the board. Events therefore
+// reach the client once each and in order, even when they were replayed on subscribing.
+func (c *Client) enqueueEvent(boardID primitive.ObjectID, message Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	// Events that could not be numbered carry no seq and are always sent
+	if message.Seq != 0 && message.Seq <= c.delivered[boardID] {
+		return true
+	}
+
+	select {
+	case c.send <- message:
+		if message.Seq == 0 {
+			return true
+		}
+		if c.delivered == nil {
+			c.delivered = make(map[primitive.ObjectID]int64)
+		}
+		c.delivered[boardID] = message.Seq
+		return true
+	default:
+		return false
+	}
+}
+
+// skipEventsThrough keeps broadcasts of the board's events up to seq from being queued
+func (c *Client) skipEventsThrough(boardID primitive.ObjectID, seq int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq <= c.delivered[boardID] {
+		return
+	}
+	if c.delivered == nil {
+		c.delivered = make(map[primitive.ObjectID]int64)
+	}
+	c.delivered[boardID] = seq
+}
+
+// forgetEvents forgets what was delivered for the board, once the client stops following it
+func (c *Client) forgetEvents(boardID primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.delivered, boardID)
+}
+
+// close closes the client's queue so that its writer sends a close frame and exits
+func (c *Client) close(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.send)
+}
+
+// writePump writes queued messages and heartbeat pings to the client's connection until
+// its queue is closed. It also closes the connection when the client goes idle or the
+// token it was opened with expires.
+func (h *Hub) writePump(c *Client) {
+	defer c.Conn.Close()
+
+	ping := time.NewTicker(h.config.PingInterval)
+	defer ping.Stop()
+
+	var expired <-chan time.Time
+	if !c.tokenExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.tokenExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+				return
+			}
+			if err := c.Conn.WriteJSON(message); err != nil {
+				log.Printf("Error writing to client: %v", err)
+				return
+			}
+
+		case <-ping.C:
+			if h.config.IdleTimeout > 0 && c.idleFor() > h.config.IdleTimeout {
+				h.removeClient(c, closeCodeIdleTimeout, closeReasonIdleTimeout)
+				continue
+			}
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-expired:
+			expired = nil
+			h.removeClient(c, closeCodeTokenExpired, closeReasonTokenExpired)
+		}
+	}
+}
+
+// follows reports whether the client wants events of the board. Clients that never
+// subscribed receive events from every board they are a member of.
+// Callers must hold the hub lock.
+func (c *Client) follows(boardID primitive.ObjectID) bool {
+	if c.boards == nil {
+		return true
+	}
+	return c.boards[boardID]
+}
+
+// removeClient forgets the client and closes its queue. It is safe to call more than once.
+func (h *Hub) removeClient(client *Client, code int, reason string) {
+	h.mu.Lock()
+	if clients, ok := h.clients[client.UserID]; ok {
+		if _, ok := clients[client]; ok {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(h.clients, client.UserID)
+			}
+			log.Printf("Client unregistered for user %s", client.UserID.Hex())
+		}
+	}
+	h.mu.Unlock()
+
+	client.close(code, reason)
+}
+
 // run handles hub operations
 func (h *Hub) run() {
 	for {
@@ -67,58 +263,230 @@ func (h *Hub) run() {
 			log.Printf("Client registered for user %s", client.UserID.Hex())
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if clients, ok := h.clients[client.UserID]; ok {
-				if _, ok := clients[client]; ok {
-					delete(clients, client)
-					client.Conn.Close()
-					if len(clients) == 0 {
-						delete(h.clients, client.UserID)
+			h.removeClient(client, websocket.CloseNormalClosure, "")
+
+		case event := <-h.broadcast:
+			// Only current members of the board that follow it receive the event
+			h.mu.RLock()
+			var targets []*Client
+			for userID := range event.members {
+				for client := range h.clients[userID] {
+					if client.follows(event.boardID) {
+						targets = append(targets, client)
 					}
-					log.Printf("Client unregistered for user %s", client.UserID.Hex())
 				}
 			}
-			h.mu.Unlock()
+			h.mu.RUnlock()
 
-		case message := <-h.broadcast:
-			// Convert message UserID to ObjectID
-			userObjectID, err := primitive.ObjectIDFromHex(message.UserID)
-			if err != nil {
-				log.Printf("Invalid user ID in broadcast message: %s", message.UserID)
-				continue
+			// Clients that cannot keep up are dropped instead of stalling everyone else
+			for _, client := range targets {
+				if !client.enqueueEvent(event.boardID, event.message) {
+					log.Printf("Dropping slow client of user %s", client.UserID.Hex())
+					h.removeClient(client, websocket.CloseTryAgainLater, closeReasonSlowConsumer)
+				}
 			}
+		}
+	}
+}
 
-			h.mu.RLock()
-			clients := h.clients[userObjectID]
-			h.mu.RUnlock()
+// subscribe adds a board to the client's subscriptions
+func (h *Hub) subscribe(client *Client, boardID primitive.ObjectID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.boards == nil {
+		client.boards = make(map[primitive.ObjectID]bool)
+	}
+	client.boards[boardID] = true
+}
 
-			// Send message to all clients of the user
-			for client := range clients {
-				err := client.Conn.WriteJSON(message)
-				if err != nil {
-					log.Printf("Error writing to client: %v", err)
-					h.unregister <- client
-				}
+// unsubscribe removes a board from the client's subscriptions
+func (h *Hub) unsubscribe(client *Client, boardID primitive.ObjectID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.boards == nil {
+		client.boards = make(map[primitive.ObjectID]bool)
+	}
+	delete(client.boards, boardID)
+	client.forgetEvents(boardID)
+}
+
+// RevokeBoardAccess drops the board from the subscriptions of all of the user's clients
+// and tells them it is no longer available. Call it whenever a user loses access to a board.
+func RevokeBoardAccess(boardID primitive.ObjectID, userID primitive.ObjectID) {
+	if hub == nil {
+		return
+	}
+
+	hub.mu.Lock()
+	var affected []*Client
+	for client := range hub.clients[userID] {
+		if client.boards != nil {
+			delete(client.boards, boardID)
+		}
+		client.forgetEvents(boardID)
+		affected = append(affected, client)
+	}
+	hub.mu.Unlock()
+
+	message := Message{Type: messageTypeUnsubscribed, BoardID: boardID.Hex(), UserID: userID.Hex()}
+	for _, client := range affected {
+		client.enqueue(message)
+	}
+}
+
+// SendToUser queues a message for every connected client of the user, whatever boards
+// they follow. Personal messages are not logged for replay. It reports whether any
+// client took the message.
+func SendToUser(userID primitive.ObjectID, message Message) bool {
+	if hub == nil {
+		return false
+	}
+
+	hub.mu.RLock()
+	var targets []*Client
+	for client := range hub.clients[userID] {
+		targets = append(targets, client)
+	}
+	hub.mu.RUnlock()
+
+	delivered := false
+	for _, client := range targets {
+		if client.enqueue(message) {
+			delivered = true
+		}
+	}
+	return delivered
+}
+
+// CloseTokenConnections closes every connection opened with the given token
+func CloseTokenConnections(tokenID string) {
+	if hub == nil {
+		return
+	}
+
+	hub.mu.RLock()
+	var affected []*Client
+	for _, clients := range hub.clients {
+		for client := range clients {
+			if client.tokenID == tokenID {
+				affected = append(affected, client)
 			}
 		}
 	}
+	hub.mu.RUnlock()
+
+	for _, client := range affected {
+		hub.removeClient(client, closeCodeTokenRevoked, closeReasonTokenRevoked)
+	}
+}
+
+// BroadcastTaskChange broadcasts a task change to the connected clients of every board member.
+// It never blocks: when the hub is backed up the event is discarded.
+func BroadcastTaskChange(messageType string, board *models.Board, task *models.Task, userID primitive.ObjectID, data interface{}) {
+	broadcastBoardEvent(board, Message{
+		Type:    messageType,
+		TaskID:  task.ID.Hex(),
+		Version: task.Version,
+		BoardID: board.ID.Hex(),
+		UserID:  userID.Hex(),
+		Data:    data,
+	})
+}
+
+// BroadcastBoardChange broadcasts a change to the board itself, such as its columns, in
+// sequence with the board's task events
+func BroadcastBoardChange(messageType string, board *models.Board, userID primitive.ObjectID, data interface{}) {
+	broadcastBoardEvent(board, Message{
+		Type:    messageType,
+		BoardID: board.ID.Hex(),
+		UserID:  userID.Hex(),
+		Data:    data,
+	})
 }
 
-// BroadcastTaskChange broadcasts a task change to all connected clients of a user
-func BroadcastTaskChange(messageType string, taskID primitive.ObjectID, userID primitive.ObjectID, data interface{}) {
+// broadcastBoardEvent numbers and logs a board event and hands it to the hub for the
+// board's members. It never blocks: when the hub is backed up the event is discarded.
+func broadcastBoardEvent(board *models.Board, message Message) {
 	if hub == nil {
 		log.Println("Warning: Hub not initialized, cannot broadcast message")
 		return
 	}
 
-	message := Message{
-		Type:   messageType,
-		TaskID: taskID.Hex(),
-		UserID: userID.Hex(),
-		Data:   data,
+	members := make(map[primitive.ObjectID]bool)
+	for _, member := range boardMembers(board) {
+		members[member.UserID] = true
 	}
 
-	hub.broadcast <- message
+	// Number and log the event, then hand it to the hub before the next event of the board
+	unlock := lockBoard(board.ID)
+	defer unlock()
+
+	if err := recordEvent(board.ID, &message); err != nil {
+		log.Printf("Error recording event for board %s: %v", board.ID.Hex(), err)
+	}
+
+	select {
+	case hub.broadcast <- boardEvent{boardID: board.ID, members: members, message: message}:
+	default:
+		log.Printf("Warning: Hub is backed up, dropping %s event for board %s", message.Type, board.ID.Hex())
+	}
+}
+
+// handleClientMessage processes a subscription request sent by a websocket client
+func handleClientMessage(client *Client, payload []byte) {
+	var req ClientMessage
+	if err := json.Unmarshal(payload, &req); err != nil || req.Action == "" {
+		// Anything that isn't a request is treated as a keep-alive ping
+		return
+	}
+
+	boardID, err := primitive.ObjectIDFromHex(req.BoardID)
+	if err != nil {
+		client.enqueue(Message{Type: messageTypeError, BoardID: req.BoardID, Data: errInvalidBoardID})
+		return
+	}
+
+	switch req.Action {
+	case clientActionSubscribe:
+		subscribeClient(client, boardID, req.Since)
+
+	case clientActionUnsubscribe:
+		hub.unsubscribe(client, boardID)
+		client.enqueue(Message{Type: messageTypeUnsubscribed, BoardID: req.BoardID, UserID: client.UserID.Hex()})
+
+	default:
+		client.enqueue(Message{Type: messageTypeError, Data: errUnknownAction})
+	}
+}
+
+// subscribeClient subscribes the client to a board and, when a cursor is given, replays
+// the events it missed since then
+func subscribeClient(client *Client, boardID primitive.ObjectID, since *int64) {
+	// Hold the board's event stream still so that no event falls between replay and live delivery
+	unlock := lockBoard(boardID)
+	defer unlock()
+
+	// Subscribing requires read access, checked against the board's current members
+	board, err := authorizeBoard(boardID, client.UserID, models.RoleViewer)
+	if err != nil {
+		client.enqueue(Message{Type: messageTypeError, BoardID: boardID.Hex(), Data: err.Error()})
+		return
+	}
+
+	// Events up to the board's current seq that are still on their way through the hub
+	// are covered by the replay, or by the client's own fetch of the board, and are not
+	// broadcast to the client again
+	client.skipEventsThrough(boardID, board.EventSeq)
+
+	hub.subscribe(client, boardID)
+	client.enqueue(Message{Type: messageTypeSubscribed, BoardID: boardID.Hex(), UserID: client.UserID.Hex(), Seq: board.EventSeq})
+
+	if since != nil {
+		if err := replayEvents(client, board, *since); err != nil {
+			log.Printf("Error replaying events for board %s: %v", boardID.Hex(), err)
+			client.enqueue(Message{Type: messageTypeResyncRequired, BoardID: boardID.Hex(), Seq: board.EventSeq})
+		}
+	}
 }
 
 // HandleWebSocket handles websocket connections
@@ -132,33 +500,61 @@ func HandleWebSocket(c *websocket.Conn) {
 	}
 
 	// Validate token and extract user ID
-	userID, err := validateTokenAndGetUserID(token)
+	userID, claims, err := validateTokenAndGetUserID(token)
 	if err != nil {
 		log.Printf("WebSocket connection rejected: %v", err)
 		c.Close()
 		return
 	}
 
-	client := &Client{
-		Conn:   c,
-		UserID: userID,
-	}
+	client := hub.newClient(c, userID, claims)
+
+	// The connection must outlive its writer, so wait for it before returning
+	done := make(chan struct{})
+	go func() {
+		hub.writePump(client)
+		close(done)
+	}()
 
 	hub.register <- client
 
 	// Keep connection alive and handle disconnection
 	defer func() {
 		hub.unregister <- client
+		<-done
 	}()
 
+	// Reconnecting clients may subscribe and catch up right away via query params
+	if boardID := c.Query(queryBoardID); boardID != "" {
+		boardObjectID, err := primitive.ObjectIDFromHex(boardID)
+		if err != nil {
+			client.enqueue(Message{Type: messageTypeError, BoardID: boardID, Data: errInvalidBoardID})
+		} else {
+			var since *int64
+			if cursor, err := strconv.ParseInt(c.Query(querySince), 10, 64); err == nil {
+				since = &cursor
+			}
+			subscribeClient(client, boardObjectID, since)
+		}
+	}
+
+	// A connection that answers neither pings nor sends anything is considered dead
+	c.SetReadDeadline(time.Now().Add(hub.config.PongTimeout))
+	c.SetPongHandler(func(string) error {
+		return c.SetReadDeadline(time.Now().Add(hub.config.PongTimeout))
+	})
+
 	for {
-		// Read messages from client (for keep-alive pings)
-		_, _, err := c.ReadMessage()
+		// Read subscription requests and keep-alive pings from the client
+		_, payload, err := c.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
+		c.SetReadDeadline(time.Now().Add(hub.config.PongTimeout))
+		client.touch()
+		handleClientMessage(client, payload)
 	}
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// drain returns the seq numbers of the messages queued for the client
+func drain(client *Client) []int64 {
+	var seqs []int64
+	for {
+		select {
+		case message := <-client.send:
+			seqs = append(seqs, message.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+func TestEnqueueEventAfterReplay(t *testing.T) {
+	client := &Client{send: make(chan Message, 10)}
+	board, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+	// Subscribing at seq 6 with since=3 replays 4 to 6
+	client.skipEventsThrough(board, 6)
+	for seq := int64(4); seq <= 6; seq++ {
+		client.enqueue(Message{Seq: seq})
+	}
+
+	// Broadcasts of 5 and 6 still on their way through the hub are dropped
+	for _, seq := range []int64{5, 6, 7, 8} {
+		if !client.enqueueEvent(board, Message{Seq: seq}) {
+			t.Fatalf("enqueueEvent(%d) failed", seq)
+		}
+	}
+	client.enqueueEvent(board, Message{Seq: 8})
+
+	// Other boards and unnumbered events are unaffected
+	client.enqueueEvent(other, Message{Seq: 1})
+	client.enqueueEvent(board, Message{})
+
+	if got, want := drain(client), []int64{4, 5, 6, 7, 8, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("queued %v, want %v", got, want)
+	}
+
+	// After unsubscribing, a new subscription starts over
+	client.forgetEvents(board)
+	client.enqueueEvent(board, Message{Seq: 2})
+	if got, want := drain(client), []int64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after forgetEvents queued %v, want %v", got, want)
+	}
+}
+
+func TestEnqueueEventReportsFullQueue(t *testing.T) {
+	client := &Client{send: make(chan Message, 1)}
+	board := primitive.NewObjectID()
+
+	if !client.enqueueEvent(board, Message{Seq: 1}) {
+		t.Fatal("first event not queued")
+	}
+	if client.enqueueEvent(board, Message{Seq: 2}) {
+		t.Fatal("event queued on a full queue")
+	}
+
+	// The dropped event was not counted as delivered
+	drain(client)
+	if !client.enqueueEvent(board, Message{Seq: 2}) {
+		t.Fatal("event not queued after the queue drained")
+	}
+	if got, want := drain(client), []int64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queued %v, want %v", got, want)
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"regexp"
 
 	"github.com/AttFlederX/kanban_board_server/config"
 	"github.com/AttFlederX/kanban_board_server/database"
 	"github.com/AttFlederX/kanban_board_server/handlers"
 	"github.com/AttFlederX/kanban_board_server/middleware"
+	"github.com/AttFlederX/kanban_board_server/notify"
+	"github.com/AttFlederX/kanban_board_server/scheduler"
+	"github.com/AttFlederX/kanban_board_server/storage"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -22,19 +27,67 @@ func main() {
 		log.Fatal("Database connection failed:", err)
 	}
 
+	handlers.SetTombstoneRetention(cfg.TombstoneRetention)
+	handlers.SetDueSoonWindow(cfg.DueSoonWindow)
+	handlers.SetSearchTextIndex(cfg.SearchTextIndex)
+	handlers.SetTrashRetention(cfg.TrashRetention)
+	if err := handlers.EnsureIndexes(); err != nil {
+		log.Fatal("Creating database indexes failed:", err)
+	}
+	if err := handlers.MigrateOrphanedTasks(); err != nil {
+		log.Fatal("Moving tasks without a board failed:", err)
+	}
+
 	// Initialize websocket hub
 	handlers.SetJWTSecret(cfg.JWTSecret)
-	handlers.InitHub()
+	handlers.InitHub(handlers.HubConfig{
+		SendQueueSize:   cfg.WSSendQueueSize,
+		BroadcastBuffer: cfg.WSBroadcastBuffer,
+		PingInterval:    cfg.WSPingInterval,
+		PongTimeout:     cfg.WSPongTimeout,
+		IdleTimeout:     cfg.WSIdleTimeout,
+		EventLogSize:    cfg.EventLogSize,
+	})
+
+	// Attachment storage
+	attachmentStorage, err := newAttachmentStorage(cfg)
+	if err != nil {
+		log.Fatal("Attachment storage setup failed:", err)
+	}
+	handlers.ConfigureAttachments(handlers.AttachmentConfig{
+		Storage: attachmentStorage,
+		MaxSize: int64(cfg.AttachmentMaxSize),
+		Types:   cfg.AttachmentTypes,
+		URLTTL:  cfg.AttachmentURLTTL,
+	})
+
+	// Start background jobs
+	handlers.SetReminderCatchUp(cfg.ReminderCatchUp)
+	if cfg.ReminderWebhookURL != "" {
+		handlers.SetReminderNotifier(notify.NewWebhook(cfg.ReminderWebhookURL))
+	}
+	jobs := scheduler.New()
+	jobs.Every("reminders", cfg.ReminderInterval, handlers.SendDueReminders)
+	jobs.Every("trash purge", cfg.TrashPurgeInterval, handlers.PurgeTrash)
+	jobs.Start()
 
-	app := fiber.New()
+	// Bodies are streamed so that only attachment uploads may exceed the default body limit
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
 
 	// Enable CORS
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,If-Match",
+		ExposeHeaders: "ETag",
 	}))
 
+	// Hold request bodies to the default limit; uploads get a larger one on their route
+	app.Use(middleware.BodyLimit(fiber.DefaultBodyLimit, isAttachmentUpload))
+
 	// Auth routes (public)
 	app.Post("/auth/google", func(c *fiber.Ctx) error {
 		return handlers.GoogleSignIn(c, cfg.JWTSecret)
@@ -43,21 +96,135 @@ func main() {
 	// WebSocket route (handles auth via token query param)
 	app.Get("/ws", websocket.New(handlers.HandleWebSocket))
 
+	// Attachment downloads through signed URLs (authorized by the signature)
+	app.Get("/attachments/:id", handlers.DownloadSignedAttachment)
+
 	// Protected routes
 	authApp := app.Group("", middleware.AuthRequired(cfg.JWTSecret))
 
+	// Auth routes (protected)
+	authApp.Post("/auth/logout", handlers.Logout)
+
+	// Sync route (protected)
+	authApp.Get("/sync", handlers.Sync)
+	authApp.Get("/search", handlers.SearchTasks)
+
 	// User routes (protected)
 	authApp.Get("/users/:id", handlers.GetUser)
 	authApp.Post("/users", handlers.CreateUser)
 	authApp.Put("/users/:id", handlers.UpdateUser)
 	authApp.Delete("/users/:id", handlers.DeleteUser)
 
+	// Current user routes (protected)
+	authApp.Get("/me/assigned", handlers.GetAssignedTasks)
+	authApp.Post("/me/undo", handlers.UndoLastAction)
+	authApp.Get("/me/reminder-preferences", handlers.GetReminderPreferences)
+	authApp.Put("/me/reminder-preferences", handlers.UpdateReminderPreferences)
+
+	// Notification routes (protected)
+	authApp.Get("/notifications", handlers.GetNotifications)
+	authApp.Post("/notifications/read-all", handlers.ReadAllNotifications)
+	authApp.Put("/notifications/:id", handlers.UpdateNotification)
+	authApp.Delete("/notifications/:id", handlers.DeleteNotification)
+
+	// Board routes (protected)
+	authApp.Get("/boards", handlers.GetBoards)
+	authApp.Get("/boards/:id", handlers.GetBoard)
+	authApp.Post("/boards", handlers.CreateBoard)
+	authApp.Put("/boards/:id", handlers.UpdateBoard)
+	authApp.Delete("/boards/:id", handlers.DeleteBoard)
+
+	// Board column routes (protected)
+	authApp.Get("/boards/:id/columns", handlers.GetColumns)
+	authApp.Post("/boards/:id/columns", handlers.CreateColumn)
+	authApp.Put("/boards/:id/columns", handlers.ReorderColumns)
+	authApp.Put("/boards/:id/columns/:columnId", handlers.UpdateColumn)
+	authApp.Delete("/boards/:id/columns/:columnId", handlers.DeleteColumn)
+
+	// Board member routes (protected)
+	authApp.Get("/boards/:id/members", handlers.GetMembers)
+	authApp.Post("/boards/:id/members", handlers.AddMember)
+	authApp.Put("/boards/:id/members/:userId", handlers.UpdateMember)
+	authApp.Delete("/boards/:id/members/:userId", handlers.RemoveMember)
+
+	// Board label routes (protected)
+	authApp.Get("/boards/:id/labels", handlers.GetLabels)
+	authApp.Post("/boards/:id/labels", handlers.CreateLabel)
+	authApp.Put("/boards/:id/labels/:labelId", handlers.UpdateLabel)
+	authApp.Delete("/boards/:id/labels/:labelId", handlers.DeleteLabel)
+
+	// Saved filter routes (protected)
+	authApp.Get("/boards/:id/filters", handlers.GetFilters)
+	authApp.Post("/boards/:id/filters", handlers.CreateFilter)
+	authApp.Put("/boards/:id/filters/:filterId", handlers.UpdateFilter)
+	authApp.Delete("/boards/:id/filters/:filterId", handlers.DeleteFilter)
+
+	// Activity, trash and archive routes (protected)
+	authApp.Get("/boards/:id/activity", handlers.GetBoardActivity)
+	authApp.Get("/boards/:id/trash", handlers.GetTrash)
+	authApp.Post("/boards/:id/archive", handlers.ArchiveDoneTasks)
+
 	// Task routes (protected)
 	authApp.Get("/tasks", handlers.GetTasks)
 	authApp.Get("/tasks/:id", handlers.GetTask)
 	authApp.Post("/tasks", handlers.CreateTask)
 	authApp.Put("/tasks/:id", handlers.UpdateTask)
+	authApp.Patch("/tasks/:id", handlers.PatchTask)
+	authApp.Post("/tasks/:id/move", handlers.MoveTask)
 	authApp.Delete("/tasks/:id", handlers.DeleteTask)
+	authApp.Get("/tasks/:id/history", handlers.GetTaskHistory)
+	authApp.Post("/tasks/:id/restore", handlers.RestoreTask)
+	authApp.Post("/tasks/:id/archive", handlers.ArchiveTask)
+	authApp.Post("/tasks/:id/unarchive", handlers.UnarchiveTask)
+	authApp.Post("/tasks/:id/assignees", handlers.AssignTask)
+	authApp.Delete("/tasks/:id/assignees/:userId", handlers.UnassignTask)
+	authApp.Get("/tasks/:id/attachments", handlers.GetAttachments)
+	authApp.Post("/tasks/:id/attachments", middleware.BodyLimit(uploadBodyLimit(cfg), nil), handlers.UploadAttachment)
+	authApp.Get("/tasks/:id/attachments/:attachmentId", handlers.DownloadAttachment)
+	authApp.Delete("/tasks/:id/attachments/:attachmentId", handlers.DeleteAttachment)
+	authApp.Get("/tasks/:id/comments", handlers.GetComments)
+	authApp.Post("/tasks/:id/comments", handlers.CreateComment)
+	authApp.Put("/tasks/:id/comments/:commentId", handlers.UpdateComment)
+	authApp.Delete("/tasks/:id/comments/:commentId", handlers.DeleteComment)
+	authApp.Post("/tasks/:id/checklist", handlers.AddChecklistItem)
+	authApp.Put("/tasks/:id/checklist", handlers.ReorderChecklist)
+	authApp.Patch("/tasks/:id/checklist/:itemId", handlers.UpdateChecklistItem)
+	authApp.Delete("/tasks/:id/checklist/:itemId", handlers.DeleteChecklistItem)
 
 	log.Fatal(app.Listen(":" + cfg.Port))
 }
+
+// multipartOverhead is the room left in a request for the multipart framing around an upload
+const multipartOverhead = 1 << 20
+
+// attachmentUploadPath matches the path of the attachment upload route
+var attachmentUploadPath = regexp.MustCompile(`(?i)^/tasks/[^/]+/attachments/?$`)
+
+// isAttachmentUpload reports whether the request uploads an attachment, which is held to
+// uploadBodyLimit rather than the default body limit
+func isAttachmentUpload(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPost && attachmentUploadPath.MatchString(c.Path())
+}
+
+// uploadBodyLimit leaves room for the largest attachment plus the rest of the multipart form
+func uploadBodyLimit(cfg *config.Config) int {
+	return max(cfg.AttachmentMaxSize+multipartOverhead, fiber.DefaultBodyLimit)
+}
+
+// newAttachmentStorage sets up the configured attachment storage
+func newAttachmentStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.AttachmentStorage {
+	case "local":
+		return storage.NewLocal(cfg.AttachmentDir)
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown ATTACHMENT_STORAGE %q, expected local or s3", cfg.AttachmentStorage)
+	}
+}
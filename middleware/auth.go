@@ -3,6 +3,7 @@ package middleware
 import (
 	"strings"
 
+	"github.com/AttFlederX/kanban_board_server/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -46,9 +47,28 @@ func AuthRequired(jwtSecret string) fiber.Handler {
 
 		// Store claims in context
 		if claims, ok := token.Claims.(*Claims); ok {
+			// Reject tokens revoked before their expiry, e.g. by signing out
+			if claims.ID != "" {
+				revoked, err := services.IsTokenRevoked(claims.ID)
+				if err != nil {
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+						"error": "Failed to verify token",
+					})
+				}
+				if revoked {
+					return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+						"error": "Token has been revoked",
+					})
+				}
+			}
+
 			c.Locals("userID", claims.UserID)
 			c.Locals("email", claims.Email)
 			c.Locals("googleID", claims.GoogleID)
+			c.Locals("tokenID", claims.ID)
+			if claims.ExpiresAt != nil {
+				c.Locals("tokenExpiresAt", claims.ExpiresAt.Time)
+			}
 		}
 
 		return c.Next()
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBodyLimit(t *testing.T) {
+	const limit = 16
+	app := fiber.New(fiber.Config{
+		BodyLimit:                    8,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+	app.Use(BodyLimit(limit, func(c *fiber.Ctx) bool { return c.Path() == "/upload" }))
+	echo := func(c *fiber.Ctx) error { return c.Send(c.Body()) }
+	app.Post("/echo", echo)
+	app.Post("/upload", BodyLimit(2*limit, nil), echo)
+
+	tests := []struct {
+		path    string
+		body    string
+		chunked bool
+		status  int
+	}{
+		{"/echo", "", false, fiber.StatusOK},
+		{"/echo", strings.Repeat("a", 4), false, fiber.StatusOK},
+		{"/echo", strings.Repeat("a", limit), false, fiber.StatusOK},
+		{"/echo", strings.Repeat("a", limit+1), false, fiber.StatusRequestEntityTooLarge},
+		{"/echo", strings.Repeat("a", limit), true, fiber.StatusOK},
+		{"/echo", strings.Repeat("a", limit+1), true, fiber.StatusRequestEntityTooLarge},
+		{"/upload", strings.Repeat("a", 2*limit), false, fiber.StatusOK},
+		{"/upload", strings.Repeat("a", 2*limit+1), false, fiber.StatusRequestEntityTooLarge},
+		{"/upload", strings.Repeat("a", 2*limit+1), true, fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		if tt.chunked {
+			// An unknown length makes the body arrive chunked
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("POST %s with %d bytes (chunked %v) = %d, want %d", tt.path, len(tt.body), tt.chunked, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == fiber.StatusOK {
+			if body, _ := io.ReadAll(resp.Body); string(body) != tt.body {
+				t.Errorf("POST %s passed on %q, want %q", tt.path, body, tt.body)
+			}
+		}
+	}
+}
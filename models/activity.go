@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Activity actions
+const (
+	ActivityCreate  = "create"
+	ActivityUpdate  = "update"
+	ActivityMove    = "move"
+	ActivityDelete  = "delete"
+	ActivityRestore = "restore"
+	ActivityUndo    = "undo"
+
+	ActivityArchive   = "archive"
+	ActivityUnarchive = "unarchive"
+)
+
+// Activity records a change to a task for its history and the board's activity feed
+type Activity struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BoardID   primitive.ObjectID `json:"boardId" bson:"boardId"`
+	TaskID    primitive.ObjectID `json:"taskId" bson:"taskId"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"` // Actor
+	Action    string             `json:"action" bson:"action"`
+	TaskName  string             `json:"taskName" bson:"taskName"` // Name of the task after the change
+	Version   int64              `json:"version" bson:"version"`   // Task version after the change
+	Changes   []FieldChange      `json:"changes" bson:"changes"`
+	Undone    bool               `json:"undone" bson:"undone"` // Reverted by its actor
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+
+	// Rank the task had before the change moved it, for undo
+	PreviousRank string `json:"-" bson:"previousRank,omitempty"`
+}
+
+// FieldChange is the change of a single task field. Both values are kept in the task's
+// JSON representation; From is null for fields set when the task was created.
+type FieldChange struct {
+	Field string          `json:"field" bson:"field"`
+	From  json.RawMessage `json:"from" bson:"from"`
+	To    json.RawMessage `json:"to" bson:"to"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is a file uploaded to a task. The file itself lives in the attachment
+// storage under StorageKey.
+type Attachment struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TaskID      primitive.ObjectID `json:"taskId" bson:"taskId"`
+	BoardID     primitive.ObjectID `json:"boardId" bson:"boardId"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"` // Uploader
+	FileName    string             `json:"fileName" bson:"fileName"`
+	ContentType string             `json:"contentType" bson:"contentType"`
+	Size        int64              `json:"size" bson:"size"` // In bytes
+	StorageKey  string             `json:"-" bson:"storageKey"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	URL         string             `json:"url,omitempty" bson:"-"` // Signed download URL, valid for a limited time
+}
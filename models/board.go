@@ -0,0 +1,56 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Board member roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+type Board struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description" bson:"description"`
+	OwnerID     primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	Columns     []Column           `json:"columns" bson:"columns"`
+	Labels      []Label            `json:"labels" bson:"labels"`
+	Members     []BoardMember      `json:"members" bson:"members"`
+	EventSeq    int64              `json:"eventSeq" bson:"eventSeq"` // Sequence number of the board's latest event
+}
+
+// Column is a board-defined task status. Tasks reference a column by its name.
+type Column struct {
+	ID    primitive.ObjectID `json:"id" bson:"_id"`
+	Name  string             `json:"name" bson:"name"`
+	Order int                `json:"order" bson:"order"`
+	Color string             `json:"color" bson:"color"`
+}
+
+// Label is a tag from the board's catalogue. Tasks reference labels by ID, so renaming
+// a label carries over to every task that has it.
+type Label struct {
+	ID    primitive.ObjectID `json:"id" bson:"_id"`
+	Name  string             `json:"name" bson:"name"`
+	Color string             `json:"color" bson:"color"`
+}
+
+// BoardMember grants a user a role on a board
+type BoardMember struct {
+	UserID primitive.ObjectID `json:"userId" bson:"userId"`
+	Role   string             `json:"role" bson:"role"`
+}
+
+// RoleOf returns the role the user holds on the board, or an empty string for non-members
+func (b *Board) RoleOf(userID primitive.ObjectID) string {
+	if b.OwnerID == userID {
+		return RoleOwner
+	}
+	for _, member := range b.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a message in a task's discussion thread
+type Comment struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TaskID    primitive.ObjectID `json:"taskId" bson:"taskId"`
+	BoardID   primitive.ObjectID `json:"boardId" bson:"boardId"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"` // Author; only they may edit the comment
+	Text      string             `json:"text" bson:"text"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	EditedAt  *time.Time         `json:"editedAt" bson:"editedAt"` // Set once the author changes the text
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event is a websocket message kept in a board's event log so that reconnecting
+// clients can catch up on what they missed
+type Event struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BoardID   primitive.ObjectID `json:"boardId" bson:"boardId"`
+	Seq       int64              `json:"seq" bson:"seq"`
+	Type      string             `json:"type" bson:"type"`
+	TaskID    string             `json:"taskId" bson:"taskId"`
+	Version   int64              `json:"version" bson:"version"`
+	UserID    string             `json:"userId" bson:"userId"`
+	Data      string             `json:"data" bson:"data"` // JSON-encoded message payload
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SavedFilter is a named task query a user keeps for a board
+type SavedFilter struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BoardID   primitive.ObjectID `json:"boardId" bson:"boardId"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	Name      string             `json:"name" bson:"name"`
+	Query     string             `json:"query" bson:"query"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification types
+const (
+	NotificationMention = "mention"
+)
+
+// Notification is an entry in a user's inbox
+type Notification struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID  `json:"userId" bson:"userId"` // Recipient
+	Type      string              `json:"type" bson:"type"`
+	ActorID   primitive.ObjectID  `json:"actorId" bson:"actorId"` // User whose action caused the notification
+	BoardID   primitive.ObjectID  `json:"boardId" bson:"boardId"`
+	TaskID    primitive.ObjectID  `json:"taskId" bson:"taskId"`
+	CommentID *primitive.ObjectID `json:"commentId" bson:"commentId"` // Set for mentions in comments
+	TaskName  string              `json:"taskName" bson:"taskName"`
+	Excerpt   string              `json:"excerpt" bson:"excerpt"` // Start of the text the user was mentioned in
+	Read      bool                `json:"read" bson:"read"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReminderPreferences says whether and when a user is reminded of tasks falling due
+type ReminderPreferences struct {
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	Enabled     bool               `json:"enabled" bson:"enabled"`
+	LeadMinutes []int              `json:"leadMinutes" bson:"leadMinutes"` // Minutes before the due date to remind at; 0 is the due date itself
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Reminder records a reminder that was sent, so that it is never sent twice. A new due
+// date re-arms the task's reminders.
+type Reminder struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TaskID      primitive.ObjectID `json:"taskId" bson:"taskId"`
+	BoardID     primitive.ObjectID `json:"boardId" bson:"boardId"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	DueDate     time.Time          `json:"dueDate" bson:"dueDate"`
+	LeadMinutes int                `json:"leadMinutes" bson:"leadMinutes"`
+	SentAt      time.Time          `json:"sentAt" bson:"sentAt"`
+}
@@ -1,11 +1,63 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Task struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name        string             `json:"name" bson:"name"`
-	Description string             `json:"description" bson:"description"`
-	Status      string             `json:"status" bson:"status"`
-	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	ID          primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Name        string               `json:"name" bson:"name"`
+	Description string               `json:"description" bson:"description"`
+	Status      string               `json:"status" bson:"status"`
+	UserID      primitive.ObjectID   `json:"userId" bson:"userId"`
+	BoardID     primitive.ObjectID   `json:"boardId" bson:"boardId"`
+	Rank        string               `json:"rank" bson:"rank"`
+	Version     int64                `json:"version" bson:"version"` // Incremented on every change
+	StartDate   *time.Time           `json:"startDate" bson:"startDate"`
+	DueDate     *time.Time           `json:"dueDate" bson:"dueDate"`
+	TimeZone    string               `json:"timeZone" bson:"timeZone"`   // IANA zone the dates were planned in
+	Labels      []primitive.ObjectID `json:"labels" bson:"labels"`       // IDs of labels from the board's catalogue
+	Assignees   []primitive.ObjectID `json:"assignees" bson:"assignees"` // IDs of the board members working on the task
+	Checklist   []ChecklistItem      `json:"checklist" bson:"checklist"`
+	Progress    ChecklistProgress    `json:"checklistProgress" bson:"checklistProgress"` // Kept in step with the checklist
+	Archived    bool                 `json:"archived" bson:"archived"`                   // Hidden from task lists but kept for reporting
+	ArchivedAt  *time.Time           `json:"archivedAt" bson:"archivedAt"`
+	CreatedAt   time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time            `json:"updatedAt" bson:"updatedAt"`
+
+	// Computed for responses from the due date and the task's column; never stored
+	Overdue bool `json:"overdue" bson:"-"`
+	DueSoon bool `json:"dueSoon" bson:"-"`
+}
+
+// ChecklistItem is a step of a task's checklist
+type ChecklistItem struct {
+	ID    primitive.ObjectID `json:"id" bson:"_id"`
+	Text  string             `json:"text" bson:"text"`
+	Done  bool               `json:"done" bson:"done"`
+	Order int                `json:"order" bson:"order"`
+}
+
+// ChecklistProgress counts the finished items of a checklist, as in 3/5
+type ChecklistProgress struct {
+	Done  int `json:"done" bson:"done"`
+	Total int `json:"total" bson:"total"`
+}
+
+// TrashedTask is a deleted task kept in its board's trash until it is restored or purged
+type TrashedTask struct {
+	Task      `bson:",inline"`
+	DeletedAt time.Time          `json:"deletedAt" bson:"deletedAt"`
+	DeletedBy primitive.ObjectID `json:"deletedBy" bson:"deletedBy"`
+	PurgeAt   time.Time          `json:"purgeAt" bson:"-"` // When the task leaves the trash for good; computed
+}
+
+// TaskTombstone records a deleted task so that syncing clients learn about the deletion
+type TaskTombstone struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	TaskID    primitive.ObjectID `json:"id" bson:"taskId"`
+	BoardID   primitive.ObjectID `json:"boardId" bson:"boardId"`
+	DeletedAt time.Time          `json:"deletedAt" bson:"deletedAt"`
 }
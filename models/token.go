@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevokedToken records a JWT that must no longer be accepted before it expires
+type RevokedToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TokenID   string             `json:"tokenId" bson:"tokenId"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,66 @@
+// Package notify delivers notifications to users outside of the websocket connection,
+// for example to a push or e-mail gateway
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Notification is a message for a single user
+type Notification struct {
+	Type    string      `json:"type"`
+	UserID  string      `json:"userId"`
+	BoardID string      `json:"boardId,omitempty"`
+	TaskID  string      `json:"taskId,omitempty"`
+	Title   string      `json:"title"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// webhookTimeout bounds a single webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// Webhook posts every notification as JSON to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook creates a notifier that posts to the URL
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Notify posts the notification. Any response other than 2xx is an error.
+func (w *Webhook) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notify: webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TermCompiler turns a single term into a Mongo filter. Errors that are not an *Error are
+// reported at the position of the term's value.
+type TermCompiler func(term *Term) (bson.M, error)
+
+// Compile turns a parsed query into a Mongo filter, compiling every term with compileTerm
+func Compile(node Node, compileTerm TermCompiler) (bson.M, error) {
+	switch n := node.(type) {
+	case *And:
+		if len(n.Nodes) == 0 {
+			return bson.M{}, nil
+		}
+		filters, err := compileAll(n.Nodes, compileTerm)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$and": filters}, nil
+
+	case *Or:
+		filters, err := compileAll(n.Nodes, compileTerm)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$or": filters}, nil
+
+	case *Not:
+		filter, err := Compile(n.Node, compileTerm)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nor": []bson.M{filter}}, nil
+
+	case *Term:
+		filter, err := compileTerm(n)
+		if err != nil {
+			var queryErr *Error
+			if errors.As(err, &queryErr) {
+				return nil, queryErr
+			}
+			return nil, &Error{Pos: n.ValuePos, Msg: err.Error()}
+		}
+		return filter, nil
+	}
+	return nil, fmt.Errorf("query: unknown node %T", node)
+}
+
+func compileAll(nodes []Node, compileTerm TermCompiler) ([]bson.M, error) {
+	filters := make([]bson.M, 0, len(nodes))
+	for _, node := range nodes {
+		filter, err := Compile(node, compileTerm)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// MongoOp maps a term's comparison operator to the Mongo operator. Terms without an
+// operator compare for equality.
+func MongoOp(op string) string {
+	switch op {
+	case "<":
+		return "$lt"
+	case "<=":
+		return "$lte"
+	case ">":
+		return "$gt"
+	case ">=":
+		return "$gte"
+	}
+	return "$eq"
+}
+
+// ParseTime parses a point in time given as a date (2006-01-02), an RFC 3339 timestamp,
+// "now", "today", or an offset from now such as 7d, -2w or 12h. Dates and "today" are
+// midnight in loc.
+func ParseTime(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	now = now.In(loc)
+	switch strings.ToLower(value) {
+	case "now":
+		return now, nil
+	case "today":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	// Offsets are a signed whole number followed by a unit
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(value) >= 2 {
+		if unit, ok := units[value[len(value)-1]]; ok {
+			if n, err := strconv.Atoi(value[:len(value)-1]); err == nil {
+				return now.Add(time.Duration(n) * unit), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q; use a date like 2006-01-02, today, or an offset like 7d", value)
+}
@@ -0,0 +1,144 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseTime(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	now := time.Date(2025, 3, 15, 22, 30, 0, 0, time.UTC) // Already March 16 in Kyiv
+
+	tests := []struct {
+		value string
+		loc   *time.Location
+		want  time.Time
+	}{
+		// Absolute
+		{"2025-01-31", time.UTC, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"2025-01-31", kyiv, time.Date(2025, 1, 31, 0, 0, 0, 0, kyiv)},
+		{"2025-01-31T10:00:00Z", kyiv, time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC)},
+		{"2025-01-31T10:00:00+02:00", time.UTC, time.Date(2025, 1, 31, 8, 0, 0, 0, time.UTC)},
+		{"now", time.UTC, now},
+		{"NOW", time.UTC, now},
+		{"today", time.UTC, time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"Today", kyiv, time.Date(2025, 3, 16, 0, 0, 0, 0, kyiv)},
+
+		// Relative
+		{"7d", time.UTC, now.Add(7 * 24 * time.Hour)},
+		{"-7d", time.UTC, now.Add(-7 * 24 * time.Hour)},
+		{"+2w", time.UTC, now.Add(14 * 24 * time.Hour)},
+		{"-2w", time.UTC, now.Add(-14 * 24 * time.Hour)},
+		{"12h", time.UTC, now.Add(12 * time.Hour)},
+		{"0d", time.UTC, now},
+		{"36h", kyiv, now.Add(36 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+" in "+tt.loc.String(), func(t *testing.T) {
+			got, err := ParseTime(tt.value, now, tt.loc)
+			if err != nil {
+				t.Fatalf("ParseTime(%q) failed: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeErrors(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	for _, value := range []string{"", "d", "7", "7x", "7.5d", "1d2h", "yesterday", "2025-13-01", "2025-02-30", "31/01/2025"} {
+		if got, err := ParseTime(value, now, time.UTC); err == nil {
+			t.Errorf("ParseTime(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestMongoOp(t *testing.T) {
+	tests := map[string]string{"": "$eq", "<": "$lt", "<=": "$lte", ">": "$gt", ">=": "$gte"}
+	for op, want := range tests {
+		if got := MongoOp(op); got != want {
+			t.Errorf("MongoOp(%q) = %q, want %q", op, got, want)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	compileTerm := func(term *Term) (bson.M, error) {
+		switch term.Key {
+		case "":
+			return bson.M{"text": term.Value}, nil
+		case "status":
+			return bson.M{"status": term.Value}, nil
+		case "due":
+			return bson.M{"due": bson.M{MongoOp(term.Op): term.Value}}, nil
+		case "is":
+			return nil, &Error{Pos: term.Pos, Msg: "reported by the compiler"}
+		}
+		return nil, fmt.Errorf("unknown field %q", term.Key)
+	}
+
+	tests := []struct {
+		input string
+		want  bson.M
+	}{
+		{"", bson.M{}},
+		{"status:doing", bson.M{"status": "doing"}},
+		{"due:<7d", bson.M{"due": bson.M{"$lt": "7d"}}},
+		{"a b", bson.M{"$and": []bson.M{{"text": "a"}, {"text": "b"}}}},
+		{"a OR b", bson.M{"$or": []bson.M{{"text": "a"}, {"text": "b"}}}},
+		{"-status:done", bson.M{"$nor": []bson.M{{"status": "done"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			node, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Compile(node, compileTerm)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compile(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		// Plain errors are reported at the term's value
+		{"status:doing colour:red", 20, `unknown field "colour"`},
+		{"(a OR -colour:red)", 14, `unknown field "colour"`},
+		// Query errors keep their own position
+		{"a is:overdue", 2, "reported by the compiler"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.input, func(t *testing.T) {
+			node, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Compile(node, compileTerm)
+			var queryErr *Error
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("Compile(%q) = %v, want a query error", tt.input, err)
+			}
+			if queryErr.Pos != tt.pos || queryErr.Msg != tt.msg {
+				t.Errorf("Compile(%q) failed with %q at %d, want %q at %d", tt.input, queryErr.Msg, queryErr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+// Package query parses the task query language used by saved filters, for example
+//
+//	status:doing assignee:me due:<7d label:bug -label:wontfix
+//
+// A query is a list of terms that must all hold. A term is either free text or a
+// key:value condition, where the value may be quoted and may start with one of the
+// comparison operators <, <=, > or >=. A leading - negates a term, OR combines
+// alternatives and parentheses group them.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error reports a problem with a query together with the position it was found at
+type Error struct {
+	Pos int    // Byte offset into the query
+	Msg string // What is wrong
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+// Node is a parsed query expression: *And, *Or, *Not or *Term
+type Node interface {
+	node()
+}
+
+// And holds when all of its nodes hold
+type And struct {
+	Nodes []Node
+}
+
+// Or holds when any of its nodes holds
+type Or struct {
+	Nodes []Node
+}
+
+// Not holds when its node does not
+type Not struct {
+	Node Node
+}
+
+// Term is a single condition. Free text has an empty Key.
+type Term struct {
+	Key      string
+	Op       string // "", "<", "<=", ">" or ">="
+	Value    string
+	Pos      int // Position of the term
+	ValuePos int // Position of the value
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Term) node() {}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenColon
+	tokenMinus
+	tokenLParen
+	tokenRParen
+	tokenOp
+)
+
+// opChars are the characters comparison operators are made of. Only < and > start an
+// operator anywhere; = and ! only do so right after a colon.
+const opChars = "<>=!"
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// describe names the token for error messages
+func (t token) describe() string {
+	if t.kind == tokenEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits the query into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == ':':
+			tokens = append(tokens, token{kind: tokenColon, text: ":", pos: i})
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+
+		case r == '-' && (len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenColon && tokens[len(tokens)-1].kind != tokenOp):
+			// A minus directly after a colon or operator belongs to the value, as in due:>-7d
+			tokens = append(tokens, token{kind: tokenMinus, text: "-", pos: i})
+			i++
+
+		case r == '<' || r == '>' || (strings.ContainsRune(opChars, r) && len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenColon):
+			// The whole run of operator characters is one token, so that the parser can
+			// reject unknown operators such as = or <> as a whole
+			j := i
+			for j < len(input) && strings.IndexByte(opChars, input[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: input[i:j], pos: i})
+			i = j
+
+		case r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(input) && input[j] != '"'; j++ {
+				if input[j] == '\\' && j+1 < len(input) {
+					j++
+				}
+				b.WriteByte(input[j])
+			}
+			if j >= len(input) {
+				return nil, &Error{Pos: i, Msg: "unterminated quoted string"}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: i})
+			i = j + 1
+
+		default:
+			start := i
+			for i < len(input) {
+				r, size := utf8.DecodeRuneInString(input[i:])
+				if unicode.IsSpace(r) || strings.ContainsRune(`:()"<>`, r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: input[start:i], pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+// MaxDepth is how deeply parentheses and negations may nest
+const MaxDepth = 32
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int // Parentheses and negations open at the current token
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// Parse parses a query. An empty query parses to an empty *And, which always holds.
+func Parse(input string) (Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{Pos: t.pos, Msg: "unexpected " + t.describe()}
+	}
+	return node, nil
+}
+
+// validOp reports whether op is one of the comparison operators
+func validOp(op string) bool {
+	switch op {
+	case "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// isOr reports whether the token is the OR keyword
+func isOr(t token) bool {
+	return t.kind == tokenWord && t.text == "OR"
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if !isOr(p.peek()) {
+		return first, nil
+	}
+	if and, ok := first.(*And); ok && len(and.Nodes) == 0 {
+		return nil, &Error{Pos: p.peek().pos, Msg: "OR must follow a term"}
+	}
+
+	or := &Or{Nodes: []Node{first}}
+	for isOr(p.peek()) {
+		orToken := p.next()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if and, ok := node.(*And); ok && len(and.Nodes) == 0 {
+			return nil, &Error{Pos: orToken.pos, Msg: "OR must be followed by a term"}
+		}
+		or.Nodes = append(or.Nodes, node)
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	and := &And{}
+	for {
+		t := p.peek()
+		if t.kind == tokenEOF || t.kind == tokenRParen || isOr(t) {
+			break
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and.Nodes = append(and.Nodes, node)
+	}
+	if len(and.Nodes) == 1 {
+		return and.Nodes[0], nil
+	}
+	return and, nil
+}
+
+// enter steps into a parenthesis or negation opened by t, refusing to nest past MaxDepth.
+// The parser recurses for each level, so the limit also bounds its stack.
+func (p *parser) enter(t token) error {
+	if p.depth >= MaxDepth {
+		return &Error{Pos: t.pos, Msg: fmt.Sprintf("query nests deeper than %d levels", MaxDepth)}
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenMinus:
+		next := p.peek()
+		if (next.kind != tokenWord && next.kind != tokenString && next.kind != tokenLParen) || isOr(next) {
+			return nil, &Error{Pos: t.pos, Msg: "- must be followed by a term"}
+		}
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		return &Not{Node: node}, nil
+
+	case tokenLParen:
+		if err := p.enter(t); err != nil {
+			return nil, err
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.depth--
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &Error{Pos: t.pos, Msg: "unclosed ("}
+		}
+		if and, ok := node.(*And); ok && len(and.Nodes) == 0 {
+			return nil, &Error{Pos: t.pos, Msg: "empty parentheses"}
+		}
+		return node, nil
+
+	case tokenString:
+		return &Term{Value: t.text, Pos: t.pos, ValuePos: t.pos}, nil
+
+	case tokenWord:
+		if p.peek().kind != tokenColon {
+			return &Term{Value: t.text, Pos: t.pos, ValuePos: t.pos}, nil
+		}
+		colon := p.next()
+
+		term := &Term{Key: strings.ToLower(t.text), Pos: t.pos}
+		if op := p.peek(); op.kind == tokenOp {
+			if !validOp(op.text) {
+				return nil, &Error{Pos: op.pos, Msg: fmt.Sprintf("unknown operator %q; use <, <=, > or >=", op.text)}
+			}
+			term.Op = p.next().text
+		}
+		value := p.next()
+		if value.kind != tokenWord && value.kind != tokenString {
+			pos := value.pos
+			if value.kind == tokenEOF {
+				pos = colon.pos
+			}
+			return nil, &Error{Pos: pos, Msg: fmt.Sprintf("expected a value for %q, found %s", term.Key, value.describe())}
+		}
+		term.Value = value.text
+		term.ValuePos = value.pos
+		return term, nil
+
+	default:
+		return nil, &Error{Pos: t.pos, Msg: "unexpected " + t.describe()}
+	}
+}
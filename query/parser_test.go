@@ -0,0 +1,218 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Node
+	}{
+		{"", &And{}},
+		{"   ", &And{}},
+		{"login", &Term{Value: "login", Pos: 0, ValuePos: 0}},
+		{"status:doing", &Term{Key: "status", Value: "doing", Pos: 0, ValuePos: 7}},
+		{"Status:Doing", &Term{Key: "status", Value: "Doing", Pos: 0, ValuePos: 7}},
+		{
+			"status:doing creator:me",
+			&And{Nodes: []Node{
+				&Term{Key: "status", Value: "doing", Pos: 0, ValuePos: 7},
+				&Term{Key: "creator", Value: "me", Pos: 13, ValuePos: 21},
+			}},
+		},
+
+		// Negation
+		{"-label:x", &Not{Node: &Term{Key: "label", Value: "x", Pos: 1, ValuePos: 7}}},
+		{"-draft", &Not{Node: &Term{Value: "draft", Pos: 1, ValuePos: 1}}},
+		{`-"needs review"`, &Not{Node: &Term{Value: "needs review", Pos: 1, ValuePos: 1}}},
+		{
+			"-(label:a OR label:b)",
+			&Not{Node: &Or{Nodes: []Node{
+				&Term{Key: "label", Value: "a", Pos: 2, ValuePos: 8},
+				&Term{Key: "label", Value: "b", Pos: 13, ValuePos: 19},
+			}}},
+		},
+		{"pre-market", &Term{Value: "pre-market", Pos: 0, ValuePos: 0}},
+
+		// Comparison operators
+		{"due:<7d", &Term{Key: "due", Op: "<", Value: "7d", Pos: 0, ValuePos: 5}},
+		{"due:<=7d", &Term{Key: "due", Op: "<=", Value: "7d", Pos: 0, ValuePos: 6}},
+		{"due:>2025-01-31", &Term{Key: "due", Op: ">", Value: "2025-01-31", Pos: 0, ValuePos: 5}},
+		{"created:>=-2w", &Term{Key: "created", Op: ">=", Value: "-2w", Pos: 0, ValuePos: 10}},
+		{"due:-7d", &Term{Key: "due", Value: "-7d", Pos: 0, ValuePos: 4}},
+		{`due:<"2025-01-31"`, &Term{Key: "due", Op: "<", Value: "2025-01-31", Pos: 0, ValuePos: 5}},
+
+		// Quoted values
+		{`label:"needs review"`, &Term{Key: "label", Value: "needs review", Pos: 0, ValuePos: 6}},
+		{`"login screen"`, &Term{Value: "login screen", Pos: 0, ValuePos: 0}},
+		{`"say \"hi\""`, &Term{Value: `say "hi"`, Pos: 0, ValuePos: 0}},
+		{`label:"a:b (c)"`, &Term{Key: "label", Value: "a:b (c)", Pos: 0, ValuePos: 6}},
+		{`""`, &Term{Value: "", Pos: 0, ValuePos: 0}},
+
+		// OR and grouping
+		{
+			"a OR b c",
+			&Or{Nodes: []Node{
+				&Term{Value: "a", Pos: 0, ValuePos: 0},
+				&And{Nodes: []Node{
+					&Term{Value: "b", Pos: 5, ValuePos: 5},
+					&Term{Value: "c", Pos: 7, ValuePos: 7},
+				}},
+			}},
+		},
+		{
+			"(a OR b) c",
+			&And{Nodes: []Node{
+				&Or{Nodes: []Node{
+					&Term{Value: "a", Pos: 1, ValuePos: 1},
+					&Term{Value: "b", Pos: 6, ValuePos: 6},
+				}},
+				&Term{Value: "c", Pos: 9, ValuePos: 9},
+			}},
+		},
+		{"a or b", &And{Nodes: []Node{
+			&Term{Value: "a", Pos: 0, ValuePos: 0},
+			&Term{Value: "or", Pos: 2, ValuePos: 2},
+			&Term{Value: "b", Pos: 5, ValuePos: 5},
+		}}},
+
+		// Positions are byte offsets
+		{"über due:<1d", &And{Nodes: []Node{
+			&Term{Value: "über", Pos: 0, ValuePos: 0},
+			&Term{Key: "due", Op: "<", Value: "1d", Pos: 6, ValuePos: 11},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, dump(got), dump(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		// Dangling colon
+		{"status:", 6, `expected a value for "status", found end of query`},
+		{"status: ", 6, `expected a value for "status", found end of query`},
+		{"status:)", 7, `expected a value for "status", found ")"`},
+		{"a status:(b)", 9, `expected a value for "status", found "("`},
+		{"due:<", 3, `expected a value for "due", found end of query`},
+		{":doing", 0, `unexpected ":"`},
+		{"status::doing", 7, `expected a value for "status", found ":"`},
+
+		// Unknown operators
+		{"due:=7d", 4, `unknown operator "="; use <, <=, > or >=`},
+		{"due:==7d", 4, `unknown operator "=="; use <, <=, > or >=`},
+		{"due:!=7d", 4, `unknown operator "!="; use <, <=, > or >=`},
+		{"label:x due:<>7d", 12, `unknown operator "<>"; use <, <=, > or >=`},
+		{"due:=<7d", 4, `unknown operator "=<"; use <, <=, > or >=`},
+		{"due:>>7d", 4, `unknown operator ">>"; use <, <=, > or >=`},
+		{"a <b", 2, `unexpected "<"`},
+
+		// Unterminated quotes
+		{`"login`, 0, "unterminated quoted string"},
+		{`label:"needs review`, 6, "unterminated quoted string"},
+		{`a "b" "c`, 6, "unterminated quoted string"},
+		{`"ends with a backslash\"`, 0, "unterminated quoted string"},
+
+		// Structure
+		{"-", 0, "- must be followed by a term"},
+		{"a - OR b", 2, "- must be followed by a term"},
+		{"--x", 0, "- must be followed by a term"},
+		{"(a", 0, "unclosed ("},
+		{"a)", 1, `unexpected ")"`},
+		{"()", 0, "empty parentheses"},
+		{"OR a", 0, "OR must follow a term"},
+		{"a OR", 2, "OR must be followed by a term"},
+		{"a OR OR b", 2, "OR must be followed by a term"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			node, err := Parse(tt.input)
+			var queryErr *Error
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("Parse(%q) = %s, %v; want a query error", tt.input, dump(node), err)
+			}
+			if queryErr.Pos != tt.pos || queryErr.Msg != tt.msg {
+				t.Errorf("Parse(%q) failed with %q at %d, want %q at %d", tt.input, queryErr.Msg, queryErr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Pos: 4, Msg: `unknown operator "="`}
+	if got, want := err.Error(), `unknown operator "=" at position 5`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+// dump formats a node for failure messages
+func dump(node Node) string {
+	switch n := node.(type) {
+	case *And:
+		return "And" + dumpAll(n.Nodes)
+	case *Or:
+		return "Or" + dumpAll(n.Nodes)
+	case *Not:
+		return "Not(" + dump(n.Node) + ")"
+	case *Term:
+		return fmt.Sprintf("%+v", *n)
+	}
+	return fmt.Sprintf("%v", node)
+}
+
+func dumpAll(nodes []Node) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		parts = append(parts, dump(node))
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func TestParseDepthLimit(t *testing.T) {
+	nested := func(open, close string, depth int) string {
+		return strings.Repeat(open, depth) + "x" + strings.Repeat(close, depth)
+	}
+
+	for _, input := range []string{nested("(", ")", MaxDepth), nested("-(", ")", MaxDepth/2)} {
+		if _, err := Parse(input); err != nil {
+			t.Errorf("Parse(%.40q) at the depth limit failed: %v", input, err)
+		}
+	}
+
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{nested("(", ")", MaxDepth+1), MaxDepth},
+		{nested("-(", ")", MaxDepth/2+1), MaxDepth},
+		// Far too deep to recurse into: the parser must stop at the limit, not run out of stack
+		{strings.Repeat("(", 1_000_000), MaxDepth},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		var queryErr *Error
+		if !errors.As(err, &queryErr) {
+			t.Fatalf("Parse(%.40q) = %v, want a *Error", tt.input, err)
+		}
+		if queryErr.Pos != tt.pos || !strings.Contains(queryErr.Msg, "deeper") {
+			t.Errorf("Parse(%.40q) = %q at %d, want the depth error at %d", tt.input, queryErr.Msg, queryErr.Pos, tt.pos)
+		}
+	}
+}
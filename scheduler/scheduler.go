@@ -0,0 +1,73 @@
+// Package scheduler runs background jobs at fixed intervals
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Job does one round of background work. now is the time the round was started.
+type Job func(now time.Time) error
+
+type entry struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+// Scheduler runs its jobs periodically, each in its own goroutine. A job never overlaps
+// itself: when a round takes longer than the interval, the missed ticks are skipped.
+type Scheduler struct {
+	entries []entry
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler without jobs
+func New() *Scheduler {
+	return &Scheduler{stop: make(chan struct{})}
+}
+
+// Every registers a job to run once on Start and then every interval. Jobs with an
+// interval of zero or less are disabled.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		log.Printf("Scheduler: %s disabled", name)
+		return
+	}
+	s.entries = append(s.entries, entry{name: name, interval: interval, job: job})
+}
+
+// Start starts running the registered jobs
+func (s *Scheduler) Start() {
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.run(e)
+	}
+}
+
+// Stop stops the jobs and waits for rounds in progress to finish
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(e entry) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.job(time.Now()); err != nil {
+			log.Printf("Scheduler: %s failed: %v", e.name, err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/AttFlederX/kanban_board_server/database"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoService struct {
@@ -86,3 +88,100 @@ func (s *MongoService) DeleteByID(id primitive.ObjectID) error {
 	_, err := database.DB.Collection(s.CollectionName).DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
+
+func (s *MongoService) DeleteMany(filter bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection(s.CollectionName).DeleteMany(ctx, filter)
+	return err
+}
+
+func (s *MongoService) UpdateMany(filter bson.M, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection(s.CollectionName).UpdateMany(ctx, filter, bson.M{"$set": update})
+	return err
+}
+
+func (s *MongoService) Count(filter bson.M) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return database.DB.Collection(s.CollectionName).CountDocuments(ctx, filter)
+}
+
+func (s *MongoService) FindWithOptions(filter bson.M, opts *options.FindOptions, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection(s.CollectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, result)
+}
+
+func (s *MongoService) Aggregate(pipeline any, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection(s.CollectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, result)
+}
+
+func (s *MongoService) EnsureIndex(keys bson.D, opts *options.IndexOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection(s.CollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts})
+	return err
+}
+
+// SetIndexExpiry changes how long documents live under the TTL index with the given
+// keys. Creating the index again with another expiry fails instead.
+func (s *MongoService) SetIndexExpiry(keys bson.D, expireAfterSeconds int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	command := bson.D{
+		{Key: "collMod", Value: s.CollectionName},
+		{Key: "index", Value: bson.D{{Key: "keyPattern", Value: keys}, {Key: "expireAfterSeconds", Value: expireAfterSeconds}}},
+	}
+	return database.DB.RunCommand(ctx, command).Err()
+}
+
+func (s *MongoService) FindOneAndUpdate(filter bson.M, update bson.M, opts *options.FindOneAndUpdateOptions, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return database.DB.Collection(s.CollectionName).FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+}
+
+func (s *MongoService) UpdateManyRaw(filter bson.M, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection(s.CollectionName).UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (s *MongoService) DeleteOne(filter bson.M) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := database.DB.Collection(s.CollectionName).DeleteOne(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"time"
+
+	"github.com/AttFlederX/kanban_board_server/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RevokedTokenService stores the IDs of JWTs revoked before their expiry
+var RevokedTokenService = NewMongoService("revoked_tokens")
+
+// RevokeToken marks a JWT as revoked until it would have expired anyway
+func RevokeToken(tokenID string, expiresAt time.Time) error {
+	revoked, err := IsTokenRevoked(tokenID)
+	if err != nil || revoked {
+		return err
+	}
+
+	_, err = RevokedTokenService.InsertOne(models.RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt})
+	return err
+}
+
+// IsTokenRevoked reports whether the JWT with the given ID has been revoked
+func IsTokenRevoked(tokenID string) (bool, error) {
+	count, err := RevokedTokenService.Count(bson.M{"tokenId": tokenID})
+	return count > 0, err
+}
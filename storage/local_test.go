@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalRejectsEscapingKeys(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// A file next to the root that an escaping key could reach
+	outside := filepath.Join(parent, "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{
+		"../secret.txt",
+		"../../etc/passwd",
+		"board/../../secret.txt",
+		"board/./task",
+		"..",
+		".",
+		"",
+		"/secret.txt",
+		"board//task",
+		"board/",
+		`..\secret.txt`,
+		`board\..\..\secret.txt`,
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+				t.Errorf("Put(%q) succeeded", key)
+			}
+			if body, err := store.Open(ctx, key); err == nil {
+				body.Close()
+				t.Errorf("Open(%q) succeeded", key)
+			}
+			if err := store.Delete(ctx, key); err == nil {
+				t.Errorf("Delete(%q) succeeded", key)
+			}
+		})
+	}
+
+	content, err := os.ReadFile(outside)
+	if err != nil || string(content) != "secret" {
+		t.Errorf("file outside the root was changed: %q, %v", content, err)
+	}
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("files were created next to the root: %v", entries)
+	}
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	key := "board/task/my file ü.txt"
+	content := []byte("hello")
+	if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "board", "task", "my file ü.txt")); err != nil {
+		t.Errorf("object not stored below the root: %v", err)
+	}
+
+	body, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Open read %q, %v; want %q", got, err, content)
+	}
+
+	// A short body is refused and leaves the stored object alone
+	if err := store.Put(ctx, key, strings.NewReader("hi"), 10, ""); err == nil {
+		t.Error("Put with a short body succeeded")
+	}
+	if body, err := store.Open(ctx, key); err != nil {
+		t.Errorf("Open after a failed Put: %v", err)
+	} else {
+		got, _ := io.ReadAll(body)
+		body.Close()
+		if !bytes.Equal(got, content) {
+			t.Errorf("failed Put replaced the object with %q", got)
+		}
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+// Package storage keeps uploaded files, on the local disk or in an S3-compatible bucket
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage stores objects under keys made of slash-separated segments, such as
+// "boardId/taskId/attachmentId"
+type Storage interface {
+	// Put stores size bytes read from body, replacing any object under the key
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Open returns the object's content; the caller closes it
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}